@@ -0,0 +1,98 @@
+package message
+
+import (
+	"context"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/cache"
+	"github.com/agnate/qlikrestapi/internal/database"
+	"github.com/google/uuid"
+)
+
+// Wraps a MessageRepository to cache Messages read by primary key. Cached Messages are
+// removed whenever they are changed. Lists are not cached, since any new Message changes them.
+type CachedMessageStorage struct {
+	storage MessageRepository
+	cache   cache.Cache
+	ttl     time.Duration
+}
+
+// Create a new cached Message storage container/service around storage.
+func NewCachedMessageStorage(storage MessageRepository, c cache.Cache, ttl time.Duration) *CachedMessageStorage {
+	return &CachedMessageStorage{
+		storage: storage,
+		cache:   c,
+		ttl:     ttl,
+	}
+}
+
+func (s *CachedMessageStorage) List(ctx context.Context) (Messages, error) {
+	return s.storage.List(ctx)
+}
+
+func (s *CachedMessageStorage) ListByUUID(ctx context.Context, uuid uuid.UUID) (Messages, error) {
+	return s.storage.ListByUUID(ctx, uuid)
+}
+
+func (s *CachedMessageStorage) ListAllByUUID(ctx context.Context, uuid uuid.UUID) (Messages, error) {
+	return s.storage.ListAllByUUID(ctx, uuid)
+}
+
+func (s *CachedMessageStorage) ListRevisionsByUUID(ctx context.Context, uuid uuid.UUID) (Revisions, error) {
+	return s.storage.ListRevisionsByUUID(ctx, uuid)
+}
+
+// Reads that must be up to date (see [database.WithPrimary]) skip the cache.
+func (s *CachedMessageStorage) Read(ctx context.Context, uuid uuid.UUID, createDate time.Time) (*Message, error) {
+	if database.UsePrimary(ctx) {
+		return s.storage.Read(ctx, uuid, createDate)
+	}
+
+	key := messageKey(uuid, createDate)
+	if msg := cache.Load[Message](ctx, s.cache, key); msg != nil {
+		return msg, nil
+	}
+
+	msg, err := s.storage.Read(ctx, uuid, createDate)
+	if err == nil && msg != nil {
+		cache.Save(ctx, s.cache, key, msg, s.ttl)
+	}
+	return msg, err
+}
+
+func (s *CachedMessageStorage) Create(ctx context.Context, msg *Message) (*Message, error) {
+	return s.storage.Create(ctx, msg)
+}
+
+func (s *CachedMessageStorage) Update(ctx context.Context, msg *Message) (*Message, error) {
+	defer cache.Invalidate(ctx, s.cache, messageKey(msg.UUID, msg.CreateDate))
+	return s.storage.Update(ctx, msg)
+}
+
+func (s *CachedMessageStorage) Delete(ctx context.Context, msg *Message) (*Message, error) {
+	defer cache.Invalidate(ctx, s.cache, messageKey(msg.UUID, msg.CreateDate))
+	return s.storage.Delete(ctx, msg)
+}
+
+func (s *CachedMessageStorage) Scrub(ctx context.Context, uuid uuid.UUID) error {
+	if err := s.storage.Scrub(ctx, uuid); err != nil {
+		return err
+	}
+
+	// Remove every Message that was scrubbed (Scrub keeps last_updated_by), since we don't
+	// know which ones are cached.
+	msgs, err := s.storage.ListAllByUUID(database.WithPrimary(ctx), uuid)
+	if err != nil {
+		return err
+	}
+	keys := make([]string, 0, len(msgs))
+	for _, msg := range msgs {
+		keys = append(keys, messageKey(msg.UUID, msg.CreateDate))
+	}
+	cache.Invalidate(ctx, s.cache, keys...)
+	return nil
+}
+
+func messageKey(uuid uuid.UUID, createDate time.Time) string {
+	return "message:" + uuid.String() + ":" + createDate.UTC().Format(time.RFC3339Nano)
+}
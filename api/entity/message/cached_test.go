@@ -0,0 +1,42 @@
+package message
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/cache"
+	"github.com/agnate/qlikrestapi/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestCachedMessageRead(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryMessageStorage()
+	storage := NewCachedMessageStorage(memory, cache.NewLRU(100), time.Minute)
+
+	author := uuid.New()
+	newMsg, _ := storage.Create(ctx, &Message{UUID: author, Message: "radar", Palindrome: true, LastUpdatedBy: author})
+	storage.Read(ctx, author, newMsg.CreateDate)
+
+	// Change the message behind the cache's back, so we can tell when the cache is used.
+	changed := *newMsg
+	changed.Message = "sword"
+	updatedMsg, _ := memory.Update(ctx, &changed)
+	if msg, _ := storage.Read(ctx, author, newMsg.CreateDate); msg == nil || msg.Message != "radar" {
+		t.Errorf("Read() = %v, should come from the cache", msg)
+	}
+
+	// Reads that must be up to date skip the cache.
+	if msg, _ := storage.Read(database.WithPrimary(ctx), author, newMsg.CreateDate); msg == nil || msg.Message != "sword" {
+		t.Errorf("Read() = %v, should skip the cache", msg)
+	}
+
+	// Deleting removes the cached message.
+	if _, err := storage.Delete(ctx, updatedMsg); err != nil {
+		t.Fatalf("an error '%s' was not expected while deleting a message", err)
+	}
+	if msg, _ := storage.Read(ctx, author, newMsg.CreateDate); msg != nil {
+		t.Errorf("Read() = %v, deleted message should not be returned", msg)
+	}
+}
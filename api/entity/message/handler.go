@@ -1,15 +1,17 @@
 package message
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/agnate/qlikrestapi/api/entity/user"
 	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/database"
 	"github.com/agnate/qlikrestapi/internal/util"
 	"github.com/agnate/qlikrestapi/internal/util/baddata"
 	"github.com/agnate/qlikrestapi/internal/validation"
@@ -17,28 +19,32 @@ import (
 )
 
 type API struct {
-	storage *MessageStorage
+	storage MessageRepository
+	users   *user.API
 }
 
-// Create a new Messages API handler.
-func New(db *sql.DB) *API {
+// Create a new Messages API handler. The Users API is used to look up API keys.
+func New(storage MessageRepository, users *user.API) *API {
 	return &API{
-		storage: NewMessageStorage(db),
+		storage: storage,
+		users:   users,
 	}
 }
 
 // Retrieve a list of all Messages.
 func (a *API) List(w http.ResponseWriter, r *http.Request) {
 	// List out data from storage.
-	msgs, err := a.storage.List()
+	msgs, err := a.storage.List(r.Context())
 	if err != nil {
-		util.Status404NoAPIEndpoint(w, r, err)
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status404NoAPIEndpoint(w, r, err)
+		}
 		return
 	}
 
 	// Output list of messages.
 	if err := a.outputList(msgs, http.StatusOK, w); err != nil {
-		util.Status500APIError(w, errors.New("could not parse data to json"))
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
 	}
 }
 
@@ -52,15 +58,42 @@ func (a *API) ListByUUID(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// List out data from storage.
-	msgs, err := a.storage.ListByUUID(validUUID.Parsed)
+	msgs, err := a.storage.ListByUUID(r.Context(), validUUID.Parsed)
 	if err != nil {
-		util.Status404NoAPIEndpoint(w, r, err)
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status404NoAPIEndpoint(w, r, err)
+		}
 		return
 	}
 
 	// Output list of messages.
 	if err := a.outputList(msgs, http.StatusOK, w); err != nil {
-		util.Status500APIError(w, errors.New("could not parse data to json"))
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
+	}
+}
+
+// Retrieve list of all Messages for the User that owns the API key in the request headers.
+func (a *API) ListMine(w http.ResponseWriter, r *http.Request) {
+	author, err := a.users.Authenticate(r)
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status401Unauthorized(w, r, err)
+		}
+		return
+	}
+
+	// List out data from storage.
+	msgs, err := a.storage.ListByUUID(r.Context(), author.UUID)
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status404NoAPIEndpoint(w, r, err)
+		}
+		return
+	}
+
+	// Output list of messages.
+	if err := a.outputList(msgs, http.StatusOK, w); err != nil {
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
 	}
 }
 
@@ -74,7 +107,10 @@ func (a *API) Read(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Read in data from storage.
-	msg, err := a.storage.Read(validUUID.Parsed, validCreateDate.Parsed)
+	msg, err := a.storage.Read(r.Context(), validUUID.Parsed, validCreateDate.Parsed)
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
 	if err != nil || msg == nil {
 		util.Status404NoAPIEndpoint(w, r, err)
 		return
@@ -82,7 +118,7 @@ func (a *API) Read(w http.ResponseWriter, r *http.Request) {
 
 	// Output the message.
 	if err := a.outputSingle(msg, http.StatusOK, w); err != nil {
-		util.Status500APIError(w, errors.New("could not parse data to json"))
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
 	}
 }
 
@@ -91,34 +127,48 @@ func (a *API) Create(w http.ResponseWriter, r *http.Request) {
 	// Get data from POST body.
 	msgInput, err := a.getJsonBody(r)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		if !util.StatusBodyTooLarge(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
 		return
 	}
 
-	// Lookup User ID based on API key provided.
-	uuid, err := a.processAPIKeyForUUID(msgInput)
+	// Lookup User based on API key provided.
+	author, err := a.processAPIKey(r.Context(), msgInput)
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
 	if err != nil {
-		baddata.New400BadData(errors.New("you must provide a valid api_key")).Render(w)
+		baddata.New400BadData(errors.New("you must provide a valid api_key")).Render(w, r)
+		return
+	}
+
+	// Unverified users are not allowed to post.
+	if !a.users.CanPost(author) {
+		baddata.New400BadData(errors.New("you must verify your email before posting messages")).Render(w, r)
 		return
 	}
 
 	// Validate and process message input.
-	msg, err := a.processMessageInput(msgInput, uuid, time.Time{})
+	msg, err := a.processMessageInput(msgInput, author.UUID, time.Time{})
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		baddata.New400BadData(err).Render(w, r)
 		return
 	}
 
 	// Create message.
-	newMsg, err := a.storage.Create(msg)
+	newMsg, err := a.storage.Create(r.Context(), msg)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		if !util.StatusDatabaseError(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
 		return
 	}
+	messagesCreated.WithLabelValues(strconv.FormatBool(newMsg.Palindrome)).Inc()
 
 	// Output newly-created message.
 	if err := a.outputSingle(newMsg, http.StatusCreated, w); err != nil {
-		util.Status500APIError(w, errors.New("could not parse data to json"))
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
 	}
 }
 
@@ -131,8 +181,12 @@ func (a *API) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Load existing Message so we can check concurrency.
-	existingMsg, err := a.storage.Read(validUUID.Parsed, validCreateDate.Parsed)
+	// Load existing Message so we can check concurrency. This must come from the primary
+	// database, since a replica may not have the latest last_updated_date yet.
+	existingMsg, err := a.storage.Read(database.WithPrimary(r.Context()), validUUID.Parsed, validCreateDate.Parsed)
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
 	if err != nil || existingMsg == nil {
 		util.Status404NoAPIEndpoint(w, r, err)
 		return
@@ -141,43 +195,56 @@ func (a *API) Update(w http.ResponseWriter, r *http.Request) {
 	// Get data from POST body.
 	msgInput, err := a.getJsonBody(r)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		if !util.StatusBodyTooLarge(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
 		return
 	}
 
 	// Check concurrency before processing.
 	if !a.isConcurrent(existingMsg, msgInput) {
-		a.getConcurrentBadData(msgInput).Render(w)
+		a.getConcurrentBadData(msgInput).Render(w, r)
 		return
 	}
 
-	// Lookup User ID based on API key provided.
-	uuid, err := a.processAPIKeyForUUID(msgInput)
+	// Lookup User based on API key provided.
+	author, err := a.processAPIKey(r.Context(), msgInput)
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
 	if err != nil {
-		baddata.New400BadData(errors.New("you must provide a valid api_key")).Render(w)
+		baddata.New400BadData(errors.New("you must provide a valid api_key")).Render(w, r)
+		return
+	}
+
+	// Unverified users are not allowed to post.
+	if !a.users.CanPost(author) {
+		baddata.New400BadData(errors.New("you must verify your email before posting messages")).Render(w, r)
 		return
 	}
 
 	// Validate and process message input.
 	msg, err := a.processMessageInput(msgInput, validUUID.Parsed, validCreateDate.Parsed)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		baddata.New400BadData(err).Render(w, r)
 		return
 	}
 
 	// Overwrite the LastUpdatedBy field in the Message.
-	msg.LastUpdatedBy = uuid
+	msg.LastUpdatedBy = author.UUID
 
 	// Update message.
-	updatedMsg, err := a.storage.Update(msg)
+	updatedMsg, err := a.storage.Update(r.Context(), msg)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		if !util.StatusDatabaseError(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
 		return
 	}
 
 	// Output updated message.
 	if err := a.outputSingle(updatedMsg, http.StatusOK, w); err != nil {
-		util.Status500APIError(w, errors.New("could not parse data to json"))
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
 	}
 }
 
@@ -190,8 +257,12 @@ func (a *API) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Load existing Message so we can check concurrency.
-	existingMsg, err := a.storage.Read(validUUID.Parsed, validCreateDate.Parsed)
+	// Load existing Message so we can check concurrency. This must come from the primary
+	// database, since a replica may not have the latest last_updated_date yet.
+	existingMsg, err := a.storage.Read(database.WithPrimary(r.Context()), validUUID.Parsed, validCreateDate.Parsed)
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
 	if err != nil || existingMsg == nil {
 		util.Status404NoAPIEndpoint(w, r, err)
 		return
@@ -200,38 +271,51 @@ func (a *API) Delete(w http.ResponseWriter, r *http.Request) {
 	// Get data from POST body.
 	msgInput, err := a.getJsonBody(r)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		if !util.StatusBodyTooLarge(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
 		return
 	}
 
 	// Check concurrency before processing.
 	if !a.isConcurrent(existingMsg, msgInput) {
-		a.getConcurrentBadData(msgInput).Render(w)
+		a.getConcurrentBadData(msgInput).Render(w, r)
 		return
 	}
 
-	// Lookup User ID based on API key provided.
-	uuid, err := a.processAPIKeyForUUID(msgInput)
+	// Lookup User based on API key provided.
+	author, err := a.processAPIKey(r.Context(), msgInput)
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
 	if err != nil {
-		baddata.New400BadData(errors.New("you must provide a valid api_key")).Render(w)
+		baddata.New400BadData(errors.New("you must provide a valid api_key")).Render(w, r)
+		return
+	}
+
+	// Unverified users are not allowed to post.
+	if !a.users.CanPost(author) {
+		baddata.New400BadData(errors.New("you must verify your email before posting messages")).Render(w, r)
 		return
 	}
 
 	// Note: No need to process MessageInput as we will use the existing message.
 
 	// We need to update the LastUpdatedBy field.
-	existingMsg.LastUpdatedBy = uuid
+	existingMsg.LastUpdatedBy = author.UUID
 
 	// Delete message.
-	deletedMsg, err := a.storage.Delete(existingMsg)
+	deletedMsg, err := a.storage.Delete(r.Context(), existingMsg)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		if !util.StatusDatabaseError(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
 		return
 	}
 
 	// Output deleted message.
 	if err := a.outputSingle(deletedMsg, http.StatusOK, w); err != nil {
-		util.Status500APIError(w, errors.New("could not parse data to json"))
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
 	}
 }
 
@@ -264,13 +348,7 @@ func (a *API) outputList(msgs Messages, successHttpStatus int, w http.ResponseWr
 
 // Parse the JSON body of a request.
 func (a *API) getJsonBody(r *http.Request) (*MessageInput, error) {
-	decoder := json.NewDecoder(r.Body)
-	var msg *MessageInput
-	err := decoder.Decode(&msg)
-	if err != nil {
-		return nil, err
-	}
-	return msg, nil
+	return util.DecodeJSONBody[MessageInput](r)
 }
 
 // Convert the a MessageInput object to a Message and fill in missing data.
@@ -305,23 +383,26 @@ func (a *API) processMessageInput(msgInput *MessageInput, uuid uuid.UUID, create
 
 // Gets the user's provided API key, checks if user is valid, and
 // retrieves the user data.
-func (a *API) processAPIKeyForUUID(msgInput *MessageInput) (uuid.UUID, error) {
+func (a *API) processAPIKey(ctx context.Context, msgInput *MessageInput) (*user.User, error) {
 	// TODO: Move this to middleware or manager instead of inside the Message handler.
-	// Otherwise will run into cyclical import problems eventually.
 
 	// Validation.
 	if len(msgInput.APIKey) <= 0 {
-		return uuid.UUID{}, errors.New("you must provide your api_key")
+		user.AuthFailures.WithLabelValues(user.AuthMissing).Inc()
+		return nil, errors.New("you must provide your api_key")
 	}
 
 	// Retrieve user account, if one exists.
-	userAPI := user.New(a.storage.db)
-	userFound, err := userAPI.GetUserByAPIKey(msgInput.APIKey)
-	if err != nil || userFound == nil {
-		return uuid.UUID{}, err
+	userFound, err := a.users.GetUserByAPIKey(ctx, msgInput.APIKey)
+	if err != nil {
+		return nil, err
+	}
+	if userFound == nil {
+		user.AuthFailures.WithLabelValues(user.AuthInvalid).Inc()
+		return nil, errors.New("no user found for api_key")
 	}
 
-	return userFound.UUID, nil
+	return userFound, nil
 }
 
 func (a *API) isConcurrent(existingMsg *Message, msgInput *MessageInput) bool {
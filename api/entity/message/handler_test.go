@@ -0,0 +1,137 @@
+package message
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/internal/apikey"
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/mailer"
+)
+
+// Build a Messages API backed by in-memory storage, with one User whose raw API key is returned.
+func newTestAPI(t *testing.T) (*API, string) {
+	users := user.NewMemoryUserStorage()
+	rawAPIKey, hash := apikey.GenerateAPIKey()
+	if _, err := users.Create(context.Background(), &user.User{Name: "Bob", Email: "bob@example.com", APIKey: apikey.HashByteToString(hash), Verified: true}); err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	return New(NewMemoryMessageStorage(), user.New(users, nil)), rawAPIKey
+}
+
+func serveTestRequest(handler http.HandlerFunc, method string, body string, slugs ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", strings.NewReader(body))
+	r = r.WithContext(myCtx.SetContextRouteData(r.Context(), slugs))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestHandlerCreateAndUpdate(t *testing.T) {
+	api, rawAPIKey := newTestAPI(t)
+
+	// Create a message.
+	w := serveTestRequest(api.Create, http.MethodPost, `{"api_key": "`+rawAPIKey+`", "message": "radar"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create() returned status %d, should be %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var created Messages
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || len(created) != 1 || !created[0].Palindrome {
+		t.Fatalf("Create() returned %s, should be a single palindrome message", w.Body)
+	}
+	msg := created[0]
+	createDate := msg.CreateDate.Format(time.RFC3339Nano)
+	lastUpdated := msg.LastUpdated.Format(time.RFC3339Nano)
+
+	// Update it with the current last_updated_date.
+	time.Sleep(time.Millisecond)
+	w = serveTestRequest(api.Update, http.MethodPut, `{"api_key": "`+rawAPIKey+`", "message": "sword", "last_updated_date": "`+lastUpdated+`"}`,
+		msg.UUID.String(), createDate)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Update() returned status %d, should be %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+
+	// Updating again with the stale last_updated_date must be rejected.
+	w = serveTestRequest(api.Update, http.MethodPut, `{"api_key": "`+rawAPIKey+`", "message": "trigger", "last_updated_date": "`+lastUpdated+`"}`,
+		msg.UUID.String(), createDate)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Update() with a stale last_updated_date returned status %d, should be %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerCreateInvalidAPIKey(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	w := serveTestRequest(api.Create, http.MethodPost, `{"api_key": "not-a-key", "message": "radar"}`)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Create() returned status %d, should be %d", w.Code, http.StatusBadRequest)
+	}
+	if msgs, _ := api.storage.List(context.Background()); len(msgs) != 0 {
+		t.Errorf("no message should be created for an invalid api_key")
+	}
+}
+
+func TestHandlerUpdateAndDeleteUnverified(t *testing.T) {
+	users := user.NewMemoryUserStorage()
+	rawAPIKey, hash := apikey.GenerateAPIKey()
+	author, err := users.Create(context.Background(), &user.User{Name: "Bob", Email: "bob@example.com", APIKey: apikey.HashByteToString(hash)})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	verifier := user.NewVerifier(mailer.NewFileMailer(t.TempDir()), "test-secret", "http://localhost:8080", "no-reply@localhost", time.Hour)
+	api := New(NewMemoryMessageStorage(), user.New(users, verifier))
+	msg, err := api.storage.Create(context.Background(), &Message{Message: "radar", Palindrome: true, LastUpdatedBy: author.UUID})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a message", err)
+	}
+	createDate := msg.CreateDate.Format(time.RFC3339Nano)
+	lastUpdated := msg.LastUpdated.Format(time.RFC3339Nano)
+
+	// Unverified users can't change existing messages either.
+	for name, handler := range map[string]http.HandlerFunc{"Update": api.Update, "Delete": api.Delete} {
+		w := serveTestRequest(handler, http.MethodPut, `{"api_key": "`+rawAPIKey+`", "message": "spam", "last_updated_date": "`+lastUpdated+`"}`,
+			msg.UUID.String(), createDate)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "verify your email") {
+			t.Errorf("%s() by an unverified user returned status %d (%s), should be %d", name, w.Code, w.Body, http.StatusBadRequest)
+		}
+	}
+	if found, _ := api.storage.Read(context.Background(), msg.UUID, msg.CreateDate); found == nil || found.Message != "radar" {
+		t.Errorf("Read() = %v, the message should be left unchanged", found)
+	}
+}
+
+func TestHandlerCreateUnverified(t *testing.T) {
+	users := user.NewMemoryUserStorage()
+	rawAPIKey, hash := apikey.GenerateAPIKey()
+	author, err := users.Create(context.Background(), &user.User{Name: "Bob", Email: "bob@example.com", APIKey: apikey.HashByteToString(hash)})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	verifier := user.NewVerifier(mailer.NewFileMailer(t.TempDir()), "test-secret", "http://localhost:8080", "no-reply@localhost", time.Hour)
+	api := New(NewMemoryMessageStorage(), user.New(users, verifier))
+
+	// Unverified users can't post.
+	w := serveTestRequest(api.Create, http.MethodPost, `{"api_key": "`+rawAPIKey+`", "message": "radar"}`)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "verify your email") {
+		t.Fatalf("Create() by an unverified user returned status %d (%s), should be %d", w.Code, w.Body, http.StatusBadRequest)
+	}
+	if msgs, _ := api.storage.List(context.Background()); len(msgs) != 0 {
+		t.Errorf("no message should be created by an unverified user")
+	}
+
+	// Once verified, they can.
+	author.Verified = true
+	if _, err := users.Update(context.Background(), author); err != nil {
+		t.Fatal(err)
+	}
+	w = serveTestRequest(api.Create, http.MethodPost, `{"api_key": "`+rawAPIKey+`", "message": "radar"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create() by a verified user returned status %d, should be %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+}
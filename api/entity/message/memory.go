@@ -0,0 +1,204 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Primary key of a Message (UUID, CreateDate).
+type memoryKey struct {
+	uuid       uuid.UUID
+	createDate int64 // Unix microseconds, matching the database timestamp precision
+}
+
+// Thread-safe, in-memory Message storage with the same behaviour as MessageStorage.
+// Useful for tests and for running the API without a database. Contexts are ignored since
+// nothing blocks for long.
+type MemoryMessageStorage struct {
+	mu        sync.RWMutex
+	msgs      map[memoryKey]*Message
+	order     []memoryKey // Insertion order, used when listing
+	revisions Revisions   // In the order they were saved
+}
+
+// Create a new, empty in-memory Message storage container/service.
+func NewMemoryMessageStorage() *MemoryMessageStorage {
+	return &MemoryMessageStorage{
+		msgs: make(map[memoryKey]*Message),
+	}
+}
+
+func (s *MemoryMessageStorage) List(ctx context.Context) (Messages, error) {
+	return s.filter(func(msg *Message) bool {
+		return !msg.Deleted
+	}), nil
+}
+
+func (s *MemoryMessageStorage) ListByUUID(ctx context.Context, uuid uuid.UUID) (Messages, error) {
+	return s.filter(func(msg *Message) bool {
+		return msg.UUID == uuid && !msg.Deleted
+	}), nil
+}
+
+func (s *MemoryMessageStorage) ListAllByUUID(ctx context.Context, uuid uuid.UUID) (Messages, error) {
+	msgs := s.filter(func(msg *Message) bool {
+		return msg.UUID == uuid || msg.LastUpdatedBy == uuid
+	})
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return msgs[i].CreateDate.Before(msgs[j].CreateDate)
+	})
+	return msgs, nil
+}
+
+func (s *MemoryMessageStorage) ListRevisionsByUUID(ctx context.Context, uuid uuid.UUID) (Revisions, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	revisions := make(Revisions, 0)
+	for _, revision := range s.revisions {
+		if revision.UUID == uuid || revision.LastUpdatedBy == uuid {
+			copied := *revision
+			revisions = append(revisions, &copied)
+		}
+	}
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].CreateDate.Before(revisions[j].CreateDate)
+	})
+	return revisions, nil
+}
+
+func (s *MemoryMessageStorage) Read(ctx context.Context, uuid uuid.UUID, createDate time.Time) (*Message, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msg, ok := s.msgs[newMemoryKey(uuid, createDate)]
+	if !ok || msg.Deleted {
+		return nil, nil
+	}
+	return copyMessage(msg), nil
+}
+
+func (s *MemoryMessageStorage) Create(ctx context.Context, msg *Message) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createDate := now()
+	key := newMemoryKey(msg.UUID, createDate)
+	if _, exists := s.msgs[key]; exists {
+		return nil, errors.New("duplicate key value violates unique constraint \"messages_pkey\"")
+	}
+
+	newMsg := &Message{
+		UUID:          msg.UUID,
+		CreateDate:    createDate,
+		Message:       msg.Message,
+		Palindrome:    msg.Palindrome,
+		LastUpdated:   createDate,
+		LastUpdatedBy: msg.LastUpdatedBy,
+	}
+	s.msgs[key] = newMsg
+	s.order = append(s.order, key)
+	return copyMessage(newMsg), nil
+}
+
+func (s *MemoryMessageStorage) Update(ctx context.Context, msg *Message) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.findConcurrent(msg)
+	if existing == nil {
+		return nil, errors.New("no rows updated")
+	}
+
+	s.revisions = append(s.revisions, &Revision{
+		UUID:          existing.UUID,
+		CreateDate:    existing.CreateDate,
+		Message:       existing.Message,
+		Palindrome:    existing.Palindrome,
+		RevisionDate:  existing.LastUpdated,
+		LastUpdatedBy: existing.LastUpdatedBy,
+	})
+
+	existing.Message = msg.Message
+	existing.Palindrome = msg.Palindrome
+	existing.LastUpdatedBy = msg.LastUpdatedBy
+	existing.LastUpdated = now()
+	return copyMessage(existing), nil
+}
+
+func (s *MemoryMessageStorage) Delete(ctx context.Context, msg *Message) (*Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing := s.findConcurrent(msg)
+	if existing == nil {
+		return nil, errors.New("no rows updated")
+	}
+
+	existing.Deleted = true
+	existing.LastUpdatedBy = msg.LastUpdatedBy
+	existing.LastUpdated = now()
+	return copyMessage(existing), nil
+}
+
+func (s *MemoryMessageStorage) Scrub(ctx context.Context, uuid uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := make(Revisions, 0, len(s.revisions))
+	for _, revision := range s.revisions {
+		if revision.UUID != uuid && revision.LastUpdatedBy != uuid {
+			kept = append(kept, revision)
+		}
+	}
+	s.revisions = kept
+
+	lastUpdated := now()
+	for _, msg := range s.msgs {
+		if msg.UUID == uuid || msg.LastUpdatedBy == uuid {
+			msg.Message = ""
+			msg.Palindrome = false
+			msg.Deleted = true
+			msg.LastUpdated = lastUpdated
+		}
+	}
+	return nil
+}
+
+// Find the stored Message to modify, but only if it has not been deleted or changed
+// since the caller last read it. Must be called with the lock held.
+func (s *MemoryMessageStorage) findConcurrent(msg *Message) *Message {
+	existing, ok := s.msgs[newMemoryKey(msg.UUID, msg.CreateDate)]
+	if !ok || existing.Deleted || !existing.LastUpdated.Equal(msg.LastUpdated) {
+		return nil
+	}
+	return existing
+}
+
+// Return copies of the Messages that match, in insertion order.
+func (s *MemoryMessageStorage) filter(match func(msg *Message) bool) Messages {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	msgs := make(Messages, 0)
+	for _, key := range s.order {
+		if msg := s.msgs[key]; match(msg) {
+			msgs = append(msgs, copyMessage(msg))
+		}
+	}
+	return msgs
+}
+
+func newMemoryKey(uuid uuid.UUID, createDate time.Time) memoryKey {
+	return memoryKey{uuid: uuid, createDate: createDate.UnixMicro()}
+}
+
+func copyMessage(msg *Message) *Message {
+	copied := *msg
+	return &copied
+}
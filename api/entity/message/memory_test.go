@@ -0,0 +1,173 @@
+package message
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestMemoryMessageCreateAndRead(t *testing.T) {
+	storage := NewMemoryMessageStorage()
+	uid := uuid.New()
+
+	newMsg, err := storage.Create(context.Background(), &Message{UUID: uid, Message: "radar", Palindrome: true, LastUpdatedBy: uid})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a message", err)
+	}
+	if newMsg.CreateDate.IsZero() || !newMsg.LastUpdated.Equal(newMsg.CreateDate) {
+		t.Errorf("create_date and last_updated_date should be set on creation, got %v and %v", newMsg.CreateDate, newMsg.LastUpdated)
+	}
+
+	msg, err := storage.Read(context.Background(), uid, newMsg.CreateDate)
+	if err != nil || msg == nil || msg.Message != "radar" {
+		t.Fatalf("Read() = %v, %v, should return the created message", msg, err)
+	}
+
+	// Changing the returned copy must not change what is stored.
+	msg.Message = "changed"
+	if stored, _ := storage.Read(context.Background(), uid, newMsg.CreateDate); stored.Message != "radar" {
+		t.Errorf("stored message was modified through a returned copy")
+	}
+}
+
+func TestMemoryMessageSoftDelete(t *testing.T) {
+	storage := NewMemoryMessageStorage()
+	uid := uuid.New()
+	newMsg, _ := storage.Create(context.Background(), &Message{UUID: uid, Message: "radar", LastUpdatedBy: uid})
+
+	if _, err := storage.Delete(context.Background(), newMsg); err != nil {
+		t.Fatalf("an error '%s' was not expected while deleting a message", err)
+	}
+
+	if msg, _ := storage.Read(context.Background(), uid, newMsg.CreateDate); msg != nil {
+		t.Errorf("deleted message should not be readable")
+	}
+	if msgs, _ := storage.List(context.Background()); len(msgs) != 0 {
+		t.Errorf("List() returned %d messages, deleted message should be hidden", len(msgs))
+	}
+	if msgs, _ := storage.ListAllByUUID(context.Background(), uid); len(msgs) != 1 || !msgs[0].Deleted {
+		t.Errorf("ListAllByUUID() should still return the deleted message")
+	}
+	if _, err := storage.Update(context.Background(), newMsg); err == nil {
+		t.Errorf("updating a deleted message should fail")
+	}
+}
+
+func TestMemoryMessageConcurrency(t *testing.T) {
+	storage := NewMemoryMessageStorage()
+	uid := uuid.New()
+	newMsg, _ := storage.Create(context.Background(), &Message{UUID: uid, Message: "radar", LastUpdatedBy: uid})
+
+	// Make sure the update gets a new last_updated_date.
+	time.Sleep(time.Millisecond)
+
+	update := *newMsg
+	update.Message = "sword"
+	updatedMsg, err := storage.Update(context.Background(), &update)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while updating a message", err)
+	}
+	if updatedMsg.Message != "sword" || !updatedMsg.LastUpdated.After(newMsg.LastUpdated) {
+		t.Errorf("Update() = %+v, should have the new message and last_updated_date", updatedMsg)
+	}
+
+	// A second update with the stale last_updated_date must be rejected.
+	stale := *newMsg
+	stale.Message = "trigger"
+	if _, err := storage.Update(context.Background(), &stale); err == nil {
+		t.Errorf("update with a stale last_updated_date should fail")
+	}
+	if _, err := storage.Delete(context.Background(), &stale); err == nil {
+		t.Errorf("delete with a stale last_updated_date should fail")
+	}
+}
+
+func TestMemoryMessageOrdering(t *testing.T) {
+	storage := NewMemoryMessageStorage()
+	first, second := uuid.New(), uuid.New()
+	for i, uid := range []uuid.UUID{first, second, first} {
+		storage.Create(context.Background(), &Message{UUID: uid, Message: string(rune('a' + i)), LastUpdatedBy: uid})
+		time.Sleep(time.Millisecond)
+	}
+
+	msgs, _ := storage.List(context.Background())
+	if len(msgs) != 3 || msgs[0].Message != "a" || msgs[1].Message != "b" || msgs[2].Message != "c" {
+		t.Errorf("List() should return messages in the order they were created")
+	}
+
+	msgs, _ = storage.ListByUUID(context.Background(), first)
+	if len(msgs) != 2 || msgs[0].Message != "a" || msgs[1].Message != "c" {
+		t.Errorf("ListByUUID() should only return the user's messages, in order")
+	}
+}
+
+func TestMemoryMessageScrub(t *testing.T) {
+	storage := NewMemoryMessageStorage()
+	uid := uuid.New()
+	created, _ := storage.Create(context.Background(), &Message{UUID: uid, Message: "radar", Palindrome: true, LastUpdatedBy: uid})
+	created.Message = "sword"
+	storage.Update(context.Background(), created)
+
+	if err := storage.Scrub(context.Background(), uid); err != nil {
+		t.Fatalf("an error '%s' was not expected while scrubbing messages", err)
+	}
+
+	msgs, _ := storage.ListAllByUUID(context.Background(), uid)
+	if len(msgs) != 1 || msgs[0].Message != "" || msgs[0].Palindrome || !msgs[0].Deleted {
+		t.Errorf("ListAllByUUID() = %+v, message should be scrubbed and deleted", msgs[0])
+	}
+	if revisions, _ := storage.ListRevisionsByUUID(context.Background(), uid); len(revisions) != 0 {
+		t.Errorf("ListRevisionsByUUID() returned %d revisions, should be removed by Scrub", len(revisions))
+	}
+}
+
+func TestMemoryMessageScrubEditor(t *testing.T) {
+	storage := NewMemoryMessageStorage()
+	author, editor := uuid.New(), uuid.New()
+	created, _ := storage.Create(context.Background(), &Message{UUID: author, Message: "radar", Palindrome: true, LastUpdatedBy: author})
+	created.Message, created.LastUpdatedBy = "sword", editor
+	storage.Update(context.Background(), created)
+
+	if msgs, _ := storage.ListAllByUUID(context.Background(), editor); len(msgs) != 1 || msgs[0].Message != "sword" {
+		t.Errorf("ListAllByUUID() = %+v, should return the message the editor last updated", msgs)
+	}
+	if err := storage.Scrub(context.Background(), editor); err != nil {
+		t.Fatalf("an error '%s' was not expected while scrubbing messages", err)
+	}
+	msgs, _ := storage.ListAllByUUID(context.Background(), author)
+	if len(msgs) != 1 || msgs[0].Message != "" || !msgs[0].Deleted {
+		t.Errorf("ListAllByUUID() = %+v, text written by the editor should be scrubbed", msgs)
+	}
+	if revisions, _ := storage.ListRevisionsByUUID(context.Background(), author); len(revisions) != 1 {
+		t.Errorf("ListRevisionsByUUID() returned %d revisions, the author's own should be kept", len(revisions))
+	}
+}
+
+func TestMemoryMessageRevisions(t *testing.T) {
+	storage := NewMemoryMessageStorage()
+	uid := uuid.New()
+	created, _ := storage.Create(context.Background(), &Message{UUID: uid, Message: "radar", Palindrome: true, LastUpdatedBy: uid})
+
+	// Every successful update saves the version it replaced.
+	created.Message = "sword"
+	updated, err := storage.Update(context.Background(), created)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while updating a message", err)
+	}
+	updated.Message = "trigger"
+	storage.Update(context.Background(), updated)
+	storage.Update(context.Background(), created) // Stale, so nothing is saved
+
+	revisions, _ := storage.ListRevisionsByUUID(context.Background(), uid)
+	if len(revisions) != 2 || revisions[0].Message != "radar" || !revisions[0].Palindrome || revisions[1].Message != "sword" {
+		t.Fatalf("ListRevisionsByUUID() = %+v, should return both replaced versions in order", revisions)
+	}
+	if !revisions[0].RevisionDate.Equal(created.LastUpdated) {
+		t.Errorf("RevisionDate = %s, should be when the version was written (%s)", revisions[0].RevisionDate, created.LastUpdated)
+	}
+	if others, _ := storage.ListRevisionsByUUID(context.Background(), uuid.New()); len(others) != 0 {
+		t.Errorf("ListRevisionsByUUID() returned %d revisions for another user, should be 0", len(others))
+	}
+}
@@ -0,0 +1,12 @@
+package message
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Messages created, split by whether they are palindromes. Served on /metrics.
+var messagesCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "qlik_messages_created_total",
+	Help: "Messages created, by whether they are palindromes.",
+}, []string{"palindrome"})
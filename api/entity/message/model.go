@@ -23,3 +23,15 @@ type Message struct {
 }
 
 type Messages []*Message
+
+// A previous version of a Message, saved each time the Message is updated.
+type Revision struct {
+	UUID          uuid.UUID `json:"user_id"`
+	CreateDate    time.Time `json:"create_date"` // With UUID, identifies the Message
+	Message       string    `json:"message"`
+	Palindrome    bool      `json:"is_palindrome"`
+	RevisionDate  time.Time `json:"revision_date"`   // When this version was written
+	LastUpdatedBy uuid.UUID `json:"last_updated_by"` // Who wrote this version
+}
+
+type Revisions []*Revision
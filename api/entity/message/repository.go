@@ -0,0 +1,46 @@
+package message
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Storage operations for Messages. Implemented by MessageStorage (SQL database) and
+// MemoryMessageStorage (in-memory, for tests and local development).
+//
+// Deleted Messages are soft-deleted: they are hidden from List, ListByUUID and Read,
+// but are still returned by ListAllByUUID. Update and Delete only succeed when the
+// Message's LastUpdated matches the stored value, to guard against concurrent changes.
+// Each Update saves the previous version of the Message as a Revision.
+// Every method takes the request context, so work is abandoned once the request ends.
+type MessageRepository interface {
+	// Retrieve a list of all Messages.
+	List(ctx context.Context) (Messages, error)
+	// Retrieve a list of all Messages for a specific User.
+	ListByUUID(ctx context.Context, uuid uuid.UUID) (Messages, error)
+	// Retrieve every Message for a specific User ordered by CreateDate, including deleted ones
+	// and other Users' Messages they last updated.
+	ListAllByUUID(ctx context.Context, uuid uuid.UUID) (Messages, error)
+	// Retrieve a specific Message by primary key (UUID, CreateDate). Returns nil if not found.
+	Read(ctx context.Context, uuid uuid.UUID, createDate time.Time) (*Message, error)
+	// Create a new Message.
+	Create(ctx context.Context, msg *Message) (*Message, error)
+	// Update an existing Message.
+	Update(ctx context.Context, msg *Message) (*Message, error)
+	// Delete an existing Message.
+	Delete(ctx context.Context, msg *Message) (*Message, error)
+	// Retrieve every Revision of a specific User's Messages, and every Revision they wrote of
+	// other Users' Messages, ordered by CreateDate, then RevisionDate.
+	ListRevisionsByUUID(ctx context.Context, uuid uuid.UUID) (Revisions, error)
+	// Scrub the text of every Message for a specific User, and of other Users' Messages they
+	// last updated, and mark them deleted, removing their Revisions and any the User wrote.
+	Scrub(ctx context.Context, uuid uuid.UUID) error
+}
+
+var (
+	_ MessageRepository = (*MessageStorage)(nil)
+	_ MessageRepository = (*MemoryMessageStorage)(nil)
+	_ MessageRepository = (*CachedMessageStorage)(nil)
+)
@@ -1,159 +1,175 @@
 package message
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"log"
 	"time"
 
+	"github.com/agnate/qlikrestapi/internal/database"
 	"github.com/google/uuid"
 )
 
+// Columns read from the messages table and the Message fields they are scanned into.
+var messageColumns = database.Columns[Message]{
+	{Name: "uuid", Field: func(m *Message) any { return &m.UUID }},
+	{Name: "create_date", Field: func(m *Message) any { return &m.CreateDate }},
+	{Name: "message", Field: func(m *Message) any { return &m.Message }},
+	{Name: "is_palindrome", Field: func(m *Message) any { return &m.Palindrome }},
+	{Name: "last_updated", Field: func(m *Message) any { return &m.LastUpdated }},
+	{Name: "last_updated_by", Field: func(m *Message) any { return &m.LastUpdatedBy }},
+	{Name: "logical_delete", Field: func(m *Message) any { return &m.Deleted }},
+}
+
+var selectMessages = "SELECT " + messageColumns.String() + " FROM messages"
+
+// Columns read from the message_revisions table and the Revision fields they are scanned into.
+var revisionColumns = database.Columns[Revision]{
+	{Name: "uuid", Field: func(r *Revision) any { return &r.UUID }},
+	{Name: "create_date", Field: func(r *Revision) any { return &r.CreateDate }},
+	{Name: "message", Field: func(r *Revision) any { return &r.Message }},
+	{Name: "is_palindrome", Field: func(r *Revision) any { return &r.Palindrome }},
+	{Name: "revision_date", Field: func(r *Revision) any { return &r.RevisionDate }},
+	{Name: "last_updated_by", Field: func(r *Revision) any { return &r.LastUpdatedBy }},
+}
+
 type MessageStorage struct {
-	db *sql.DB
+	database.Binding
 }
 
 // Create a new Message storage container/service.
 func NewMessageStorage(db *sql.DB) *MessageStorage {
 	return &MessageStorage{
-		db: db,
+		Binding: database.NewBinding(db),
 	}
 }
 
-// Retrieve a list of all Messages.
-func (s *MessageStorage) List() (Messages, error) {
-	return s.scanMessages("SELECT * FROM messages WHERE logical_delete = $1", false)
+// Get a copy of the storage that runs everything inside an existing transaction, so
+// it can be shared with other storage (ex: UserStorage) in a multi-entity operation.
+// See [database.Binding.WithTx].
+func (s *MessageStorage) WithTx(tx *sql.Tx) *MessageStorage {
+	return &MessageStorage{Binding: s.Binding.WithTx(tx)}
 }
 
-// Retrieve a list of all Messages for a specific User.
-func (s *MessageStorage) ListByUUID(uuid uuid.UUID) (Messages, error) {
-	return s.scanMessages("SELECT * FROM messages WHERE uuid = $1 AND logical_delete = $2", uuid, false)
+// Get a copy of the storage that aborts any query taking longer than timeout.
+func (s *MessageStorage) WithQueryTimeout(timeout time.Duration) *MessageStorage {
+	return &MessageStorage{Binding: s.Binding.WithQueryTimeout(timeout)}
 }
 
-// Retrieve a specific Message by primary key (UUID, CreateDate)
-func (s *MessageStorage) Read(uuid uuid.UUID, createDate time.Time) (*Message, error) {
-	msgs, err := s.scanMessages("SELECT * FROM messages WHERE uuid = $1 AND create_date = $2 AND logical_delete = $3", uuid, createDate, false)
-	if err == nil && len(msgs) > 0 {
-		return msgs[0], nil
-	}
-	return nil, err
+// Get a copy of the storage that sends List, ListByUUID, ListAllByUUID, ListRevisionsByUUID
+// and Read queries to the read replicas. See [database.Binding.WithReplicas].
+func (s *MessageStorage) WithReplicas(replicas ...*sql.DB) *MessageStorage {
+	return &MessageStorage{Binding: s.Binding.WithReplicas(replicas...)}
 }
 
-func (s *MessageStorage) scanMessages(query string, queryParams ...any) (Messages, error) {
-	msgs := make([]*Message, 0)
-
-	rows, err := s.db.Query(query, queryParams...)
-	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
-		return msgs, err
-	}
-
-	for rows.Next() {
-		msg := &Message{}
-		// TODO: Stop making assumptions about how data will be returned, since this fails if the db schema changes.
-		rows.Scan(&msg.UUID, &msg.CreateDate, &msg.Message, &msg.Palindrome, &msg.LastUpdated, &msg.LastUpdatedBy, &msg.Deleted)
-		msgs = append(msgs, msg)
-	}
+// Retrieve a list of all Messages.
+func (s *MessageStorage) List(ctx context.Context) (Messages, error) {
+	return s.scanMessages(ctx, "messages.list", selectMessages+" WHERE logical_delete = $1", false)
+}
 
-	return msgs, nil
+// Retrieve a list of all Messages for a specific User.
+func (s *MessageStorage) ListByUUID(ctx context.Context, uuid uuid.UUID) (Messages, error) {
+	return s.scanMessages(ctx, "messages.list_by_uuid", selectMessages+" WHERE uuid = $1 AND logical_delete = $2", uuid, false)
 }
 
-// Create a new Message.
-func (s *MessageStorage) Create(msg *Message) (*Message, error) {
-	// Create the new Message.
-	_, err := s.db.Exec("INSERT INTO messages(uuid, message, is_palindrome, last_updated_by) VALUES($1, $2, $3, $4)",
-		msg.UUID, msg.Message, msg.Palindrome, msg.LastUpdatedBy)
-	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
-		return nil, err
-	}
-	// Retrieve the message.
-	newMsg, err := s.getLatest(msg.UUID)
-	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
-		return nil, err
-	}
-	return newMsg, nil
+// Retrieve every Message for a specific User, including deleted ones and other Users'
+// Messages they last updated (ex: for data exports).
+func (s *MessageStorage) ListAllByUUID(ctx context.Context, uuid uuid.UUID) (Messages, error) {
+	return s.scanMessages(ctx, "messages.list_all_by_uuid", selectMessages+" WHERE uuid = $1 OR last_updated_by = $1 ORDER BY create_date", uuid)
 }
 
-func (s *MessageStorage) getLatest(uuid uuid.UUID) (*Message, error) {
-	msgs, err := s.scanMessages("SELECT * FROM messages WHERE uuid = $1 AND logical_delete = $2 ORDER BY create_date DESC LIMIT 1", uuid, false)
+// Retrieve a specific Message by primary key (UUID, CreateDate)
+func (s *MessageStorage) Read(ctx context.Context, uuid uuid.UUID, createDate time.Time) (*Message, error) {
+	msgs, err := s.scanMessages(ctx, "messages.read", selectMessages+" WHERE uuid = $1 AND create_date = $2 AND logical_delete = $3", uuid, createDate, false)
 	if err == nil && len(msgs) > 0 {
 		return msgs[0], nil
 	}
 	return nil, err
 }
 
-func (s *MessageStorage) get(uuid uuid.UUID, createDate time.Time) (*Message, error) {
-	msgs, err := s.scanMessages("SELECT * FROM messages WHERE uuid = $1 AND create_date = $2", uuid, createDate)
-	if err == nil && len(msgs) > 0 {
-		return msgs[0], nil
-	}
-	return nil, err
+// Retrieve every Revision of a specific User's Messages, and every Revision they wrote of
+// other Users' Messages (ex: for data exports).
+func (s *MessageStorage) ListRevisionsByUUID(ctx context.Context, uuid uuid.UUID) (Revisions, error) {
+	return database.Select(ctx, s.Binding, "messages.list_revisions_by_uuid", revisionColumns,
+		"SELECT "+revisionColumns.String()+" FROM message_revisions WHERE uuid = $1 OR last_updated_by = $1 ORDER BY create_date, revision_date", uuid)
 }
 
-// Update an existing Message.
-func (s *MessageStorage) Update(msg *Message) (*Message, error) {
-	// Update the Message.
-	result, err := s.db.Exec("UPDATE messages SET message = $1, is_palindrome = $2, last_updated_by = $3, last_updated = $4 "+
-		"WHERE uuid = $5 AND create_date = $6 AND last_updated = $7 AND logical_delete = $8",
-		msg.Message, msg.Palindrome, msg.LastUpdatedBy, time.Now(), msg.UUID, msg.CreateDate, msg.LastUpdated, false)
-	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
-		return nil, err
-	}
+func (s *MessageStorage) scanMessages(ctx context.Context, name string, query string, queryParams ...any) (Messages, error) {
+	return database.Select(ctx, s.Binding, name, messageColumns, query, queryParams...)
+}
 
-	// Check if any rows were updated.
-	rows, _ := result.RowsAffected()
-	if rows <= 0 {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		// TODO: Prefer to use custom errors here so we can have better error handling to users.
-		return nil, errors.New("no rows updated")
-	}
+// Create a new Message.
+func (s *MessageStorage) Create(ctx context.Context, msg *Message) (*Message, error) {
+	// Timestamps are set here rather than by the database, since not every database
+	// supports sub-second CURRENT_TIMESTAMP (and CreateDate is part of the key).
+	createDate := now()
+	return s.writeMessage(ctx, "messages.create", "INSERT INTO messages(uuid, create_date, message, is_palindrome, last_updated, last_updated_by) VALUES($1, $2, $3, $4, $5, $6)",
+		msg.UUID, createDate, msg.Message, msg.Palindrome, createDate, msg.LastUpdatedBy)
+}
 
-	// Retrieve the message.
-	// TODO: Make this transaction-based to avoid inconsistent read (if another update/delete)
-	// has happened in between UPDATE and READ.
-	updatedMsg, err := s.Read(msg.UUID, msg.CreateDate)
+// Update an existing Message, saving the version it replaces as a Revision.
+func (s *MessageStorage) Update(ctx context.Context, msg *Message) (*Message, error) {
+	var updatedMsg *Message
+	err := s.Atomic(ctx, func(tx *sql.Tx) error {
+		bound := s.WithTx(tx)
+
+		// Nothing is saved if the concurrency check fails, since the UPDATE below then
+		// fails as well and rolls the transaction back.
+		err := bound.Exec(ctx, "messages.save_revision", "INSERT INTO message_revisions(uuid, create_date, revision_date, message, is_palindrome, last_updated_by) "+
+			"SELECT uuid, create_date, last_updated, message, is_palindrome, last_updated_by FROM messages "+
+			"WHERE uuid = $1 AND create_date = $2 AND last_updated = $3 AND logical_delete = $4",
+			msg.UUID, msg.CreateDate, msg.LastUpdated, false)
+		if err != nil {
+			return err
+		}
+
+		updatedMsg, err = bound.writeMessage(ctx, "messages.update", "UPDATE messages SET message = $1, is_palindrome = $2, last_updated_by = $3, last_updated = $4 "+
+			"WHERE uuid = $5 AND create_date = $6 AND last_updated = $7 AND logical_delete = $8",
+			msg.Message, msg.Palindrome, msg.LastUpdatedBy, now(), msg.UUID, msg.CreateDate, msg.LastUpdated, false)
+		return err
+	})
 	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
 		return nil, err
 	}
 	return updatedMsg, nil
 }
 
 // Delete an existing Message.
-func (s *MessageStorage) Delete(msg *Message) (*Message, error) {
-	// Delete the Message.
-	result, err := s.db.Exec("UPDATE messages SET logical_delete = $1, last_updated_by = $2, last_updated = $3 "+
+func (s *MessageStorage) Delete(ctx context.Context, msg *Message) (*Message, error) {
+	return s.writeMessage(ctx, "messages.delete", "UPDATE messages SET logical_delete = $1, last_updated_by = $2, last_updated = $3 "+
 		"WHERE uuid = $4 AND create_date = $5 AND last_updated = $6 AND logical_delete = $7",
-		true, msg.LastUpdatedBy, time.Now(), msg.UUID, msg.CreateDate, msg.LastUpdated, false)
-	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
-		return nil, err
-	}
+		true, msg.LastUpdatedBy, now(), msg.UUID, msg.CreateDate, msg.LastUpdated, false)
+}
+
+// Write a Message with [database.Write], traced as name (ex: "messages.update").
+func (s *MessageStorage) writeMessage(ctx context.Context, name string, query string, queryParams ...any) (*Message, error) {
+	msg, err := database.Write(ctx, s.Binding, name, messageColumns, query, queryParams...)
 
-	// Check if any rows were updated.
-	rows, _ := result.RowsAffected()
-	if rows <= 0 {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
+	// No row is returned when the WHERE clause didn't match (ex: failed concurrency check).
+	if errors.Is(err, sql.ErrNoRows) {
+		// TODO: Prefer to use custom errors here so we can have better error handling to users.
 		return nil, errors.New("no rows updated")
 	}
+	return msg, err
+}
 
-	// Retrieve the message.
-	// TODO: Make this transaction-based to avoid inconsistent read (if another update/delete)
-	// has happened in between UPDATE and READ.
-	deletedMsg, err := s.get(msg.UUID, msg.CreateDate)
-	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
-		return nil, err
-	}
-	return deletedMsg, nil
+// Scrub the text of every Message for a specific User, and of other Users' Messages whose
+// current text they wrote, and mark them deleted. The rows are kept so that primary and
+// foreign keys remain intact, but their Revisions (and any the User wrote) are removed.
+func (s *MessageStorage) Scrub(ctx context.Context, uuid uuid.UUID) error {
+	return s.Atomic(ctx, func(tx *sql.Tx) error {
+		bound := s.WithTx(tx)
+		if err := bound.Exec(ctx, "messages.delete_revisions", "DELETE FROM message_revisions WHERE uuid = $1 OR last_updated_by = $1", uuid); err != nil {
+			return err
+		}
+		return bound.Exec(ctx, "messages.scrub", "UPDATE messages SET message = $1, is_palindrome = $2, logical_delete = $3, last_updated = $4 WHERE uuid = $5 OR last_updated_by = $5",
+			"", false, true, now(), uuid)
+	})
+}
+
+// Current time with the same precision and location as a database timestamp, so values
+// we write can be compared exactly later on (ex: for concurrency checks). Timestamps are
+// stored in UTC, and the Postgres column defaults match (see migrations/5_use_utc_timestamps).
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
 }
@@ -0,0 +1,161 @@
+package message
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/internal/database/dbtest"
+)
+
+func TestSQLiteMessageLifecycle(t *testing.T) {
+	db := dbtest.NewSQLite(t)
+	storage := NewMessageStorage(db)
+
+	author, err := user.NewUserStorage(db).Create(context.Background(), &user.User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+
+	// Create.
+	newMsg, err := storage.Create(context.Background(), &Message{UUID: author.UUID, Message: "radar", Palindrome: true, LastUpdatedBy: author.UUID})
+	if err != nil || newMsg == nil {
+		t.Fatalf("Create() = %v, %v, should return the new message", newMsg, err)
+	}
+
+	// Read it back by primary key.
+	msg, err := storage.Read(context.Background(), author.UUID, newMsg.CreateDate)
+	if err != nil || msg == nil || msg.Message != "radar" || !msg.Palindrome {
+		t.Fatalf("Read() = %v, %v, should return the created message", msg, err)
+	}
+
+	// Update with the current last_updated_date.
+	msg.Message = "sword"
+	msg.Palindrome = false
+	updatedMsg, err := storage.Update(context.Background(), msg)
+	if err != nil || updatedMsg.Message != "sword" {
+		t.Fatalf("Update() = %v, %v, should return the updated message", updatedMsg, err)
+	}
+
+	// A stale last_updated_date must be rejected, without saving a revision.
+	if _, err := storage.Update(context.Background(), msg); err == nil {
+		t.Errorf("update with a stale last_updated_date should fail")
+	}
+	if _, err := storage.Delete(context.Background(), msg); err == nil {
+		t.Errorf("delete with a stale last_updated_date should fail")
+	}
+
+	// The version replaced by the update is kept as a revision.
+	revisions, err := storage.ListRevisionsByUUID(context.Background(), author.UUID)
+	if err != nil || len(revisions) != 1 || revisions[0].Message != "radar" || !revisions[0].RevisionDate.Equal(newMsg.LastUpdated) {
+		t.Fatalf("ListRevisionsByUUID() = %v, %v, should return the original text", revisions, err)
+	}
+
+	// Delete with the current last_updated_date.
+	deletedMsg, err := storage.Delete(context.Background(), updatedMsg)
+	if err != nil || !deletedMsg.Deleted {
+		t.Fatalf("Delete() = %v, %v, should return the deleted message", deletedMsg, err)
+	}
+	if msgs, _ := storage.List(context.Background()); len(msgs) != 0 {
+		t.Errorf("List() returned %d messages, deleted message should be hidden", len(msgs))
+	}
+	if msgs, _ := storage.ListAllByUUID(context.Background(), author.UUID); len(msgs) != 1 {
+		t.Errorf("ListAllByUUID() returned %d messages, should include the deleted message", len(msgs))
+	}
+
+	// Scrubbing removes the revisions too.
+	if err := storage.Scrub(context.Background(), author.UUID); err != nil {
+		t.Fatalf("an error '%s' was not expected while scrubbing", err)
+	}
+	if revisions, _ := storage.ListRevisionsByUUID(context.Background(), author.UUID); len(revisions) != 0 {
+		t.Errorf("ListRevisionsByUUID() returned %d revisions after Scrub, should be 0", len(revisions))
+	}
+}
+
+func TestSQLiteMessageScrubEditor(t *testing.T) {
+	db := dbtest.NewSQLite(t)
+	storage := NewMessageStorage(db)
+	users := user.NewUserStorage(db)
+
+	author, _ := users.Create(context.Background(), &user.User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	editor, err := users.Create(context.Background(), &user.User{Name: "Ann", Email: "ann@example.com", APIKey: "hash2"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+
+	// The editor replaces the author's text, then the author edits it again.
+	msg, _ := storage.Create(context.Background(), &Message{UUID: author.UUID, Message: "radar", Palindrome: true, LastUpdatedBy: author.UUID})
+	msg.Message, msg.LastUpdatedBy = "level", editor.UUID
+	msg, err = storage.Update(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while updating a message", err)
+	}
+	other, _ := storage.Create(context.Background(), &Message{UUID: author.UUID, Message: "kayak", Palindrome: true, LastUpdatedBy: author.UUID})
+	other.Message, other.LastUpdatedBy = "sword", editor.UUID
+	other, _ = storage.Update(context.Background(), other)
+	other.Message, other.LastUpdatedBy = "stats", author.UUID
+	storage.Update(context.Background(), other)
+
+	// The editor's data covers the text they wrote in the author's messages.
+	if msgs, _ := storage.ListAllByUUID(context.Background(), editor.UUID); len(msgs) != 1 || msgs[0].Message != "level" {
+		t.Errorf("ListAllByUUID() = %v, should return the message the editor last updated", msgs)
+	}
+	if revisions, _ := storage.ListRevisionsByUUID(context.Background(), editor.UUID); len(revisions) != 1 || revisions[0].Message != "sword" {
+		t.Errorf("ListRevisionsByUUID() = %v, should return the revision the editor wrote", revisions)
+	}
+
+	// Scrubbing the editor removes their text, but leaves the author's own.
+	if err := storage.Scrub(context.Background(), editor.UUID); err != nil {
+		t.Fatalf("an error '%s' was not expected while scrubbing", err)
+	}
+	if found, _ := storage.Read(context.Background(), author.UUID, msg.CreateDate); found != nil {
+		t.Errorf("Read() = %v, message last updated by the editor should be scrubbed and deleted", found)
+	}
+	if found, _ := storage.Read(context.Background(), author.UUID, other.CreateDate); found == nil || found.Message != "stats" {
+		t.Errorf("Read() = %v, message last updated by the author should be kept", found)
+	}
+	revisions, _ := storage.ListRevisionsByUUID(context.Background(), author.UUID)
+	for _, revision := range revisions {
+		if revision.LastUpdatedBy == editor.UUID {
+			t.Errorf("ListRevisionsByUUID() = %v, revisions written by the editor should be removed", revision)
+		}
+	}
+	if len(revisions) != 2 {
+		t.Errorf("ListRevisionsByUUID() returned %d revisions, should keep the author's 2", len(revisions))
+	}
+}
+
+func TestSQLiteStorageIgnoresAddedColumns(t *testing.T) {
+	db := dbtest.NewSQLite(t)
+
+	// Simulate a later migration adding columns the storage doesn't know about.
+	for _, stmt := range []string{
+		"ALTER TABLE users ADD COLUMN nickname text NOT NULL DEFAULT 'bobby'",
+		"ALTER TABLE messages ADD COLUMN language text NOT NULL DEFAULT 'en'",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("an error '%s' was not expected when running %s", err, stmt)
+		}
+	}
+
+	users := user.NewUserStorage(db)
+	author, err := users.Create(context.Background(), &user.User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	if found, err := users.GetUserByAPIKey(context.Background(), "hash"); err != nil || found == nil || found.Name != "Bob" {
+		t.Errorf("GetUserByAPIKey() = %v, %v, should find the created user", found, err)
+	}
+
+	storage := NewMessageStorage(db)
+	newMsg, err := storage.Create(context.Background(), &Message{UUID: author.UUID, Message: "radar", Palindrome: true, LastUpdatedBy: author.UUID})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a message", err)
+	}
+	if msgs, err := storage.List(context.Background()); err != nil || len(msgs) != 1 || msgs[0].Message != "radar" {
+		t.Errorf("List() = %v, %v, should return the created message", msgs, err)
+	}
+	if msg, err := storage.Read(context.Background(), author.UUID, newMsg.CreateDate); err != nil || msg == nil || !msg.Palindrome {
+		t.Errorf("Read() = %v, %v, should return the created message", msg, err)
+	}
+}
@@ -1,11 +1,16 @@
 package message
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/agnate/qlikrestapi/internal/database"
+	"github.com/google/uuid"
 )
 
 var listSelectQuery string = "^SELECT (.+) FROM messages WHERE logical_delete = \\$1$"
@@ -29,7 +34,7 @@ func TestMessageListHasResult(t *testing.T) {
 	storage := NewMessageStorage(db)
 
 	// Run and validate.
-	if msgs, err := storage.List(); err != nil || len(msgs) != wantRows {
+	if msgs, err := storage.List(context.Background()); err != nil || len(msgs) != wantRows {
 		t.Errorf("error was not expected while listing messages: %s", err)
 	}
 
@@ -57,7 +62,7 @@ func TestMessageListNoResults(t *testing.T) {
 	storage := NewMessageStorage(db)
 
 	// Run and validate.
-	if msgs, err := storage.List(); err != nil || len(msgs) != wantRows {
+	if msgs, err := storage.List(context.Background()); err != nil || len(msgs) != wantRows {
 		t.Errorf("error was not expected while listing messages: %s", err)
 	}
 
@@ -81,7 +86,7 @@ func TestMessageListError(t *testing.T) {
 	storage := NewMessageStorage(db)
 
 	// Run and validate.
-	if _, err := storage.List(); err == nil {
+	if _, err := storage.List(context.Background()); err == nil {
 		t.Errorf("error WAS expected while listing messages: %s", err)
 	}
 
@@ -90,15 +95,147 @@ func TestMessageListError(t *testing.T) {
 	}
 }
 
+func TestMessageListScanError(t *testing.T) {
+	// Mock the database.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// Return a row that can't be scanned into a Message.
+	rows := getMessageRows(1).AddRow("not-a-uuid", "2001-01-02T00:00:00.0Z", "test", false, "2001-01-02T00:00:00.0Z", "not-a-uuid", false)
+
+	// We expect a query to be run, and the rows to be closed even though scanning fails.
+	mock.ExpectQuery(listSelectQuery).WithArgs(false).WillReturnRows(rows).RowsWillBeClosed()
+
+	// Instantiate storage.
+	storage := NewMessageStorage(db)
+
+	// Run and validate.
+	if msgs, err := storage.List(context.Background()); err == nil || len(msgs) != 0 {
+		t.Errorf("List() = %v, %v, should return the scan error and no messages", msgs, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestMessageListRowError(t *testing.T) {
+	// Mock the database.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// Fail part way through reading the rows.
+	rows := getMessageRows(2).RowError(1, fmt.Errorf("some error"))
+
+	// We expect a query to be run.
+	mock.ExpectQuery(listSelectQuery).WithArgs(false).WillReturnRows(rows).RowsWillBeClosed()
+
+	// Instantiate storage.
+	storage := NewMessageStorage(db)
+
+	// Run and validate.
+	if msgs, err := storage.List(context.Background()); err == nil || len(msgs) != 0 {
+		t.Errorf("List() = %v, %v, should return the row error and no messages", msgs, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func getMessageRows(count int) *sqlmock.Rows {
-	rows := sqlmock.NewRows([]string{"user_id", "create_date", "message", "is_palindrome", "last_updated_date", "last_updated_by"})
+	rows := sqlmock.NewRows([]string{"uuid", "create_date", "message", "is_palindrome", "last_updated", "last_updated_by", "logical_delete"})
 	if count > 0 {
 		for i := 0; i < count; i++ {
 			itxt := strconv.Itoa(i)
-			uid := "userid-" + itxt
-			date := "2001-01-02T00:00:00.0Z"
-			rows.AddRow(uid, date, "test"+itxt, false, date, uid)
+			uid := uuid.New().String()
+			date := time.Date(2001, 1, 2, 0, 0, i, 0, time.UTC)
+			rows.AddRow(uid, date, "test"+itxt, false, date, uid, false)
 		}
 	}
 	return rows
 }
+
+func TestMessageListTimeout(t *testing.T) {
+	// Mock the database.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// We expect a query to be run that takes longer than the timeout.
+	mock.ExpectQuery(listSelectQuery).WithArgs(false).WillDelayFor(time.Second).WillReturnRows(getMessageRows(1))
+
+	// Instantiate storage.
+	storage := NewMessageStorage(db).WithQueryTimeout(10 * time.Millisecond)
+
+	// Run and validate.
+	if _, err := storage.List(context.Background()); !errors.Is(err, database.ErrTimeout) {
+		t.Errorf("List() error = %v, should be a timeout", err)
+	}
+}
+
+func TestMessageListCancelled(t *testing.T) {
+	// Mock the database.
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// We expect a query to be run, but the request is cancelled (ex: client disconnected) first.
+	mock.ExpectQuery(listSelectQuery).WithArgs(false).WillDelayFor(time.Second).WillReturnRows(getMessageRows(1))
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	// Instantiate storage.
+	storage := NewMessageStorage(db)
+
+	// Run and validate.
+	if _, err := storage.List(ctx); !errors.Is(err, database.ErrUnavailable) {
+		t.Errorf("List() error = %v, should be unavailable", err)
+	}
+}
+
+func TestMessageListUsesReplica(t *testing.T) {
+	// Mock the primary and replica databases.
+	primary, primaryMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer primary.Close()
+	replica, replicaMock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer replica.Close()
+
+	// Listing goes to the replica, while a read marked for the primary skips it.
+	replicaMock.ExpectQuery(listSelectQuery).WithArgs(false).WillReturnRows(getMessageRows(1))
+	primaryMock.ExpectQuery("^SELECT (.+) FROM messages WHERE uuid = \\$1").WillReturnRows(getMessageRows(1))
+
+	// Instantiate storage.
+	storage := NewMessageStorage(primary).WithReplicas(replica)
+
+	// Run and validate.
+	if msgs, err := storage.List(context.Background()); err != nil || len(msgs) != 1 {
+		t.Errorf("List() = %v, %v, should return the message from the replica", msgs, err)
+	}
+	if msg, err := storage.Read(database.WithPrimary(context.Background()), uuid.New(), time.Now()); err != nil || msg == nil {
+		t.Errorf("Read() = %v, %v, should return the message from the primary", msg, err)
+	}
+
+	if err := primaryMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled primary expectations: %s", err)
+	}
+	if err := replicaMock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled replica expectations: %s", err)
+	}
+}
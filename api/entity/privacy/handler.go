@@ -0,0 +1,343 @@
+// Handles personal data requests (GDPR-style export and erasure) for a User.
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/message"
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/api/store"
+	"github.com/agnate/qlikrestapi/internal/apikey"
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/util"
+	"github.com/agnate/qlikrestapi/internal/validation"
+	"github.com/google/uuid"
+)
+
+// Returned by authorize when the API key is valid but belongs to another User.
+var errNotOwner = errors.New("api key does not belong to this user")
+
+type API struct {
+	store         *store.Store
+	userAPI       *user.API
+	complianceKey string
+}
+
+// Create a new Privacy API handler. Requests are allowed for the User's own API key, or
+// for the compliance API key (if one is configured) so requests can be handled on their behalf.
+func New(store *store.Store, userAPI *user.API, complianceKey string) *API {
+	return &API{
+		store:         store,
+		userAPI:       userAPI,
+		complianceKey: complianceKey,
+	}
+}
+
+// Download a zip archive of all personal data stored for a User: their profile, all of
+// their Messages (including deleted ones), other Users' Messages they last updated and
+// every previous version of those Messages, as both JSON and CSV.
+func (a *API) Export(w http.ResponseWriter, r *http.Request) {
+	// Validate route data from context.
+	validUUID, err := a.validateUUID(r)
+	if err != nil {
+		util.Status404NoAPIEndpoint(w, r, err)
+		return
+	}
+
+	// Check the requester is allowed access.
+	if err := a.authorize(r, validUUID.Parsed); err != nil {
+		statusUnauthorized(w, r, err)
+		return
+	}
+
+	// Load the user's data.
+	profile, err := a.store.Users.GetUserByUUID(r.Context(), validUUID.Parsed)
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
+	if err != nil || profile == nil {
+		util.Status404NoAPIEndpoint(w, r, err)
+		return
+	}
+
+	msgs, err := a.store.Messages.ListAllByUUID(r.Context(), validUUID.Parsed)
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status500APIError(w, r, err)
+		}
+		return
+	}
+
+	revisions, err := a.store.Messages.ListRevisionsByUUID(r.Context(), validUUID.Parsed)
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status500APIError(w, r, err)
+		}
+		return
+	}
+
+	// Build the archive in memory first so a failure can still be reported as an error.
+	archive, err := buildArchive(newExportProfile(profile), newExportMessages(msgs), newExportRevisions(revisions))
+	if err != nil {
+		util.Status500APIError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%s-export.zip"`, validUUID.Parsed))
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// Erase a User's personal data. The User row is anonymized and the text of their Messages
+// (and of other Users' Messages they last updated) is scrubbed, but the rows are kept so
+// that references to them remain valid.
+func (a *API) Erase(w http.ResponseWriter, r *http.Request) {
+	// Validate route data from context.
+	validUUID, err := a.validateUUID(r)
+	if err != nil {
+		util.Status404NoAPIEndpoint(w, r, err)
+		return
+	}
+
+	// Check the requester is allowed access.
+	if err := a.authorize(r, validUUID.Parsed); err != nil {
+		statusUnauthorized(w, r, err)
+		return
+	}
+
+	// Check the user exists, since the compliance key is accepted for any UUID.
+	profile, err := a.store.Users.GetUserByUUID(r.Context(), validUUID.Parsed)
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
+	if err != nil || profile == nil {
+		util.Status404NoAPIEndpoint(w, r, err)
+		return
+	}
+
+	// Scrub messages and anonymize the user in a single transaction, so we never end up
+	// with only part of the user's data erased.
+	var msgs message.Messages
+	err = a.store.WithTx(r.Context(), func(tx *store.Store) error {
+		// Count messages first so we can report how many were erased.
+		msgs, err = tx.Messages.ListAllByUUID(r.Context(), validUUID.Parsed)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Messages.Scrub(r.Context(), validUUID.Parsed); err != nil {
+			return err
+		}
+
+		// Replace the API key with a fresh one that is never shown to anyone.
+		_, hash := apikey.GenerateAPIKey()
+		_, err := tx.Users.Anonymize(r.Context(), validUUID.Parsed, apikey.HashByteToString(hash))
+		return err
+	})
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status500APIError(w, r, err)
+		}
+		return
+	}
+
+	// Output the result.
+	jsonData, err := json.Marshal([]*Erasure{{UUID: validUUID.Parsed, Erased: true, MessagesErased: len(msgs)}})
+	if err != nil {
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
+		return
+	}
+	util.APIJsonHeaders(w)
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonData)
+}
+
+// Check the API key in the request headers belongs to the User (or is the compliance key).
+func (a *API) authorize(r *http.Request, uuid uuid.UUID) error {
+	// Compliance requests are made on behalf of the User.
+	rawAPIKey := user.APIKeyFromRequest(r)
+	if len(a.complianceKey) > 0 && subtle.ConstantTimeCompare([]byte(rawAPIKey), []byte(a.complianceKey)) == 1 {
+		myCtx.GetLogger(r.Context()).Info("compliance request", "user", uuid, "method", r.Method, "path", r.URL.Path)
+		return nil
+	}
+
+	// Otherwise, Users can only access their own data.
+	requester, err := a.userAPI.Authenticate(r)
+	if err != nil {
+		return err
+	}
+	if requester.UUID != uuid {
+		user.AuthFailures.WithLabelValues(user.AuthForbidden).Inc()
+		return errNotOwner
+	}
+	return nil
+}
+
+// Respond to a failed authorize: 403 for another User's API key, 401 for a missing or invalid one.
+func statusUnauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case util.StatusDatabaseError(w, r, err):
+	case errors.Is(err, errNotOwner):
+		util.Status403Forbidden(w, r, err)
+	default:
+		util.Status401Unauthorized(w, r, err)
+	}
+}
+
+func (a *API) validateUUID(r *http.Request) (*validation.RuleUUID, error) {
+	// TODO: Improve slug management so handler doesn't need to know the index
+	uuidSlugIndex := 0
+
+	// Get route data from context.
+	rawUuid, err := myCtx.GetSlug(r.Context(), uuidSlugIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate API slugs.
+	return validation.NewRuleUUID(rawUuid)
+}
+
+func newExportProfile(u *user.User) *ExportProfile {
+	return &ExportProfile{
+		UUID:       u.UUID,
+		Name:       u.Name,
+		Email:      u.Email,
+		Verified:   u.Verified,
+		CreateDate: u.CreateDate,
+		LastAccess: u.LastAccess,
+	}
+}
+
+func newExportMessages(msgs message.Messages) ExportMessages {
+	exported := make(ExportMessages, 0, len(msgs))
+	for _, msg := range msgs {
+		exported = append(exported, &ExportMessage{
+			UUID:          msg.UUID,
+			CreateDate:    msg.CreateDate,
+			Message:       msg.Message,
+			Palindrome:    msg.Palindrome,
+			LastUpdated:   msg.LastUpdated,
+			LastUpdatedBy: msg.LastUpdatedBy,
+			Deleted:       msg.Deleted,
+		})
+	}
+	return exported
+}
+
+func newExportRevisions(revisions message.Revisions) ExportRevisions {
+	exported := make(ExportRevisions, 0, len(revisions))
+	for _, revision := range revisions {
+		exported = append(exported, &ExportRevision{
+			UUID:          revision.UUID,
+			CreateDate:    revision.CreateDate,
+			Message:       revision.Message,
+			Palindrome:    revision.Palindrome,
+			RevisionDate:  revision.RevisionDate,
+			LastUpdatedBy: revision.LastUpdatedBy,
+		})
+	}
+	return exported
+}
+
+// Write the profile, messages and revisions into a zip archive.
+func buildArchive(profile *ExportProfile, msgs ExportMessages, revisions ExportRevisions) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	if err := writeJson(archive, "profile.json", profile); err != nil {
+		return nil, err
+	}
+	if err := writeJson(archive, "messages.json", msgs); err != nil {
+		return nil, err
+	}
+	if err := writeMessagesCsv(archive, "messages.csv", msgs); err != nil {
+		return nil, err
+	}
+	if err := writeJson(archive, "revisions.json", revisions); err != nil {
+		return nil, err
+	}
+	if err := writeRevisionsCsv(archive, "revisions.csv", revisions); err != nil {
+		return nil, err
+	}
+
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJson(archive *zip.Writer, name string, data any) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+func writeMessagesCsv(archive *zip.Writer, name string, msgs ExportMessages) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"user_id", "create_date", "message", "is_palindrome", "last_updated_date", "last_updated_by", "deleted"})
+	for _, msg := range msgs {
+		writer.Write([]string{
+			msg.UUID.String(),
+			msg.CreateDate.Format(time.RFC3339Nano),
+			csvText(msg.Message),
+			strconv.FormatBool(msg.Palindrome),
+			msg.LastUpdated.Format(time.RFC3339Nano),
+			msg.LastUpdatedBy.String(),
+			strconv.FormatBool(msg.Deleted),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeRevisionsCsv(archive *zip.Writer, name string, revisions ExportRevisions) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(f)
+	writer.Write([]string{"user_id", "create_date", "message", "is_palindrome", "revision_date", "last_updated_by"})
+	for _, revision := range revisions {
+		writer.Write([]string{
+			revision.UUID.String(),
+			revision.CreateDate.Format(time.RFC3339Nano),
+			csvText(revision.Message),
+			strconv.FormatBool(revision.Palindrome),
+			revision.RevisionDate.Format(time.RFC3339Nano),
+			revision.LastUpdatedBy.String(),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// Prefix text starting with a character spreadsheets treat as a formula (ex: "=SUM(A1)")
+// with a single quote, so opening the CSV doesn't run anything a User wrote.
+func csvText(text string) string {
+	if len(text) > 0 && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
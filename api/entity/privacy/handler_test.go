@@ -0,0 +1,219 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/message"
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/api/store"
+	"github.com/agnate/qlikrestapi/internal/apikey"
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/google/uuid"
+)
+
+const testComplianceKey = "compliance-secret"
+
+// Create a User with a Message that has been edited once, and return them with their raw API key.
+func createTestUser(t *testing.T, s *store.Store, email string) (*user.User, string) {
+	t.Helper()
+	rawAPIKey, hash := apikey.GenerateAPIKey()
+	created, err := s.Users.Create(context.Background(), &user.User{Name: "Bob", Email: email, APIKey: apikey.HashByteToString(hash), Verified: true})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	msg, err := s.Messages.Create(context.Background(), &message.Message{UUID: created.UUID, Message: "radar", Palindrome: true, LastUpdatedBy: created.UUID})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a message", err)
+	}
+	msg.Message = "sword"
+	if _, err := s.Messages.Update(context.Background(), msg); err != nil {
+		t.Fatalf("an error '%s' was not expected while updating a message", err)
+	}
+	return created, rawAPIKey
+}
+
+func serveTestRequest(handler http.HandlerFunc, method string, rawAPIKey string, uuid uuid.UUID) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/", nil)
+	r = r.WithContext(myCtx.SetContextRouteData(r.Context(), []string{uuid.String()}))
+	if len(rawAPIKey) > 0 {
+		r.Header.Set(user.APIKeyHeader, rawAPIKey)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestHandlerAuthorize(t *testing.T) {
+	s := store.NewMemory()
+	owner, ownerKey := createTestUser(t, s, "bob@example.com")
+	_, otherKey := createTestUser(t, s, "ann@example.com")
+
+	tests := []struct {
+		name          string
+		complianceKey string
+		rawAPIKey     string
+		wantStatus    int
+	}{
+		{"own api key", testComplianceKey, ownerKey, http.StatusOK},
+		{"compliance key", testComplianceKey, testComplianceKey, http.StatusOK},
+		{"another user's api key", testComplianceKey, otherKey, http.StatusForbidden},
+		{"invalid api key", testComplianceKey, "not-a-key", http.StatusUnauthorized},
+		{"missing api key", testComplianceKey, "", http.StatusUnauthorized},
+		{"compliance key when disabled", "", testComplianceKey, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := New(s, user.New(s.Users, nil), tt.complianceKey)
+			if w := serveTestRequest(api.Export, http.MethodGet, tt.rawAPIKey, owner.UUID); w.Code != tt.wantStatus {
+				t.Errorf("Export() returned status %d, should be %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				return
+			}
+
+			// Erase must be refused in the same way, leaving the data untouched.
+			if w := serveTestRequest(api.Erase, http.MethodPost, tt.rawAPIKey, owner.UUID); w.Code != tt.wantStatus {
+				t.Errorf("Erase() returned status %d, should be %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			if found, _ := s.Users.GetUserByUUID(context.Background(), owner.UUID); found == nil || found.Name != "Bob" {
+				t.Errorf("the user should not be erased")
+			}
+		})
+	}
+}
+
+func TestHandlerExportIncludesRevisions(t *testing.T) {
+	s := store.NewMemory()
+	owner, ownerKey := createTestUser(t, s, "bob@example.com")
+	api := New(s, user.New(s.Users, nil), "")
+
+	w := serveTestRequest(api.Export, http.MethodGet, ownerKey, owner.UUID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Export() returned status %d, should be %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when reading the archive", err)
+	}
+	f, err := archive.Open("revisions.json")
+	if err != nil {
+		t.Fatalf("archive is missing revisions.json")
+	}
+	defer f.Close()
+	data, _ := io.ReadAll(f)
+
+	var revisions ExportRevisions
+	if err := json.Unmarshal(data, &revisions); err != nil || len(revisions) != 1 || revisions[0].Message != "radar" {
+		t.Errorf("revisions.json = %s, should contain the original text of the edited message", data)
+	}
+}
+
+func TestHandlerEditedMessages(t *testing.T) {
+	s := store.NewMemory()
+	owner, _ := createTestUser(t, s, "bob@example.com")
+	editor, editorKey := createTestUser(t, s, "ann@example.com")
+	api := New(s, user.New(s.Users, nil), "")
+
+	// The editor replaces the text of the owner's message.
+	msgs, _ := s.Messages.ListByUUID(context.Background(), owner.UUID)
+	msgs[0].Message, msgs[0].LastUpdatedBy = "level", editor.UUID
+	if _, err := s.Messages.Update(context.Background(), msgs[0]); err != nil {
+		t.Fatalf("an error '%s' was not expected while updating a message", err)
+	}
+
+	// The editor's export includes the text they wrote.
+	w := serveTestRequest(api.Export, http.MethodGet, editorKey, editor.UUID)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Export() returned status %d, should be %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	archive, _ := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	f, err := archive.Open("messages.json")
+	if err != nil {
+		t.Fatalf("archive is missing messages.json")
+	}
+	defer f.Close()
+	var exported ExportMessages
+	if err := json.NewDecoder(f).Decode(&exported); err != nil || len(exported) != 2 {
+		t.Fatalf("messages.json has %d messages, should be 2: %v", len(exported), err)
+	}
+	if edited := exported[0]; edited.UUID != owner.UUID || edited.Message != "level" {
+		t.Errorf("messages.json = %+v, should include the owner's message the editor last updated", edited)
+	}
+
+	// Erasing the editor scrubs it.
+	if w := serveTestRequest(api.Erase, http.MethodPost, editorKey, editor.UUID); w.Code != http.StatusOK {
+		t.Fatalf("Erase() returned status %d, should be %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if found, _ := s.Messages.Read(context.Background(), owner.UUID, msgs[0].CreateDate); found != nil {
+		t.Errorf("Read() = %v, the text written by the erased user should be scrubbed", found)
+	}
+}
+
+func TestHandlerEraseUnknownUser(t *testing.T) {
+	s := store.NewMemory()
+	api := New(s, user.New(s.Users, nil), testComplianceKey)
+	if w := serveTestRequest(api.Erase, http.MethodPost, testComplianceKey, uuid.New()); w.Code != http.StatusNotFound {
+		t.Errorf("Erase() returned status %d, should be %d: %s", w.Code, http.StatusNotFound, w.Body)
+	}
+}
+
+func TestBuildArchiveContents(t *testing.T) {
+	uid := uuid.New()
+	date := time.Date(2024, 6, 5, 6, 8, 16, 0, time.UTC)
+	profile := &ExportProfile{UUID: uid, Name: "Bob", Email: "bob@example.com"}
+	msgs := ExportMessages{
+		{UUID: uid, CreateDate: date, Message: "radar", Palindrome: true, LastUpdated: date, LastUpdatedBy: uid},
+		{UUID: uid, CreateDate: date.Add(time.Hour), Message: "sword, with a comma", LastUpdated: date, LastUpdatedBy: uid, Deleted: true},
+		{UUID: uid, CreateDate: date.Add(2 * time.Hour), Message: "=HYPERLINK(\"http://example.com\")", LastUpdated: date, LastUpdatedBy: uid},
+	}
+
+	revisions := ExportRevisions{
+		{UUID: uid, CreateDate: date, Message: "level", Palindrome: true, RevisionDate: date, LastUpdatedBy: uid},
+	}
+
+	data, err := buildArchive(profile, msgs, revisions)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when building the archive", err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when reading the archive", err)
+	}
+
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	for _, name := range []string{"profile.json", "messages.json", "messages.csv", "revisions.json", "revisions.csv"} {
+		if files[name] == nil {
+			t.Fatalf("archive is missing %s", name)
+		}
+	}
+
+	// Check the CSV has a header plus one row per message, including deleted ones.
+	f, _ := files["messages.csv"].Open()
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when reading messages.csv", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("messages.csv has %d records, should be 4", len(records))
+	}
+	if records[2][2] != "sword, with a comma" || records[2][6] != "true" {
+		t.Errorf("messages.csv row = %v, should contain the deleted message", records[2])
+	}
+	if records[3][2] != `'=HYPERLINK("http://example.com")` {
+		t.Errorf("messages.csv row = %v, formulas should be prefixed so spreadsheets show them as text", records[3])
+	}
+}
@@ -0,0 +1,49 @@
+package privacy
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Everything we store about a User, as included in their data export.
+type ExportProfile struct {
+	UUID       uuid.UUID `json:"user_id"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Verified   bool      `json:"verified"`
+	CreateDate time.Time `json:"create_date"`
+	LastAccess time.Time `json:"last_access"`
+}
+
+// A Message as included in a data export, including deleted ones.
+type ExportMessage struct {
+	UUID          uuid.UUID `json:"user_id"`
+	CreateDate    time.Time `json:"create_date"`
+	Message       string    `json:"message"`
+	Palindrome    bool      `json:"is_palindrome"`
+	LastUpdated   time.Time `json:"last_updated_date"`
+	LastUpdatedBy uuid.UUID `json:"last_updated_by"`
+	Deleted       bool      `json:"deleted"`
+}
+
+type ExportMessages []*ExportMessage
+
+// A previous version of a Message as included in a data export.
+type ExportRevision struct {
+	UUID          uuid.UUID `json:"user_id"`
+	CreateDate    time.Time `json:"create_date"`
+	Message       string    `json:"message"`
+	Palindrome    bool      `json:"is_palindrome"`
+	RevisionDate  time.Time `json:"revision_date"`
+	LastUpdatedBy uuid.UUID `json:"last_updated_by"`
+}
+
+type ExportRevisions []*ExportRevision
+
+// Result of erasing a User's personal data.
+type Erasure struct {
+	UUID           uuid.UUID `json:"user_id"`
+	Erased         bool      `json:"erased"`
+	MessagesErased int       `json:"messages_erased"`
+}
@@ -0,0 +1,113 @@
+package user
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Header clients can use to send their API key.
+const APIKeyHeader = "X-API-Key"
+
+// Get the raw API key sent in the request headers, either as X-API-Key or as an
+// "Authorization: Bearer" token. Returns an empty string if neither is present.
+func APIKeyFromRequest(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get(APIKeyHeader)); len(key) > 0 {
+		return key
+	}
+	scheme, key, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if found && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(key)
+	}
+	return ""
+}
+
+// How much of a request body is read to find its api_key field. API keys are short, so the
+// field fits easily unless it comes after a long value (ex: a long message), in which case
+// the request is treated as having no API key.
+const maxAPIKeyPeek = 1 << 10
+
+// Get the raw API key sent in the request headers or, failing that, in the api_key field of
+// a JSON request body (as Message writes do), looking at most maxAPIKeyPeek bytes in. The
+// body is put back as it was, so handlers can still read it (and still get any error, ex:
+// for a body over the size limit).
+func APIKeyFromRequestOrBody(r *http.Request) string {
+	if key := APIKeyFromRequest(r); len(key) > 0 {
+		return key
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return ""
+	}
+
+	peek, err := io.ReadAll(io.LimitReader(r.Body, maxAPIKeyPeek))
+	if err != nil {
+		r.Body = replayedBody{io.MultiReader(bytes.NewReader(peek), errReader{err}), r.Body}
+		return ""
+	}
+	r.Body = replayedBody{io.MultiReader(bytes.NewReader(peek), r.Body), r.Body}
+	return apiKeyField(peek)
+}
+
+// Find the api_key field of a JSON object, which may be cut off part way through.
+func apiKeyField(data []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if token, err := decoder.Token(); err != nil || token != json.Delim('{') {
+		return ""
+	}
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if token == "api_key" {
+			var key string
+			if decoder.Decode(&key) != nil {
+				return ""
+			}
+			return key
+		}
+		// Skip the value of any other field.
+		var skip json.RawMessage
+		if decoder.Decode(&skip) != nil {
+			return ""
+		}
+	}
+	return ""
+}
+
+// Request body with the part that was already read put back in front.
+type replayedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// Fails every read with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// Resolve the User that owns the API key sent in the request headers.
+func (a *API) Authenticate(r *http.Request) (*User, error) {
+	rawAPIKey := APIKeyFromRequest(r)
+	if len(rawAPIKey) <= 0 {
+		AuthFailures.WithLabelValues(AuthMissing).Inc()
+		return nil, errors.New("you must provide your api key in the " + APIKeyHeader + " header")
+	}
+
+	user, err := a.GetUserByAPIKey(r.Context(), rawAPIKey)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		AuthFailures.WithLabelValues(AuthInvalid).Inc()
+		return nil, errors.New("you must provide a valid api key")
+	}
+	return user, nil
+}
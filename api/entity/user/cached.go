@@ -0,0 +1,123 @@
+package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/cache"
+	"github.com/google/uuid"
+)
+
+// Wraps a UserRepository to cache lookups by UUID and by API key, since every authenticated
+// request looks up its User. Cached Users are removed whenever they are changed.
+//
+// API key lookups are cached by the API key hash, so raw API keys are never stored.
+type CachedUserStorage struct {
+	storage UserRepository
+	cache   cache.Cache
+	ttl     time.Duration
+}
+
+// Create a new cached User storage container/service around storage.
+func NewCachedUserStorage(storage UserRepository, c cache.Cache, ttl time.Duration) *CachedUserStorage {
+	return &CachedUserStorage{
+		storage: storage,
+		cache:   c,
+		ttl:     ttl,
+	}
+}
+
+func (s *CachedUserStorage) List(ctx context.Context) (Users, error) {
+	return s.storage.List(ctx)
+}
+
+func (s *CachedUserStorage) Create(ctx context.Context, user *User) (*User, error) {
+	return s.storage.Create(ctx, user)
+}
+
+func (s *CachedUserStorage) Update(ctx context.Context, user *User) (updatedUser *User, err error) {
+	err = s.change(ctx, user.UUID, func() error {
+		updatedUser, err = s.storage.Update(ctx, user)
+		return err
+	})
+	return updatedUser, err
+}
+
+func (s *CachedUserStorage) GetUserByUUID(ctx context.Context, uuid uuid.UUID) (*User, error) {
+	if user := cache.Load[User](ctx, s.cache, userUUIDKey(uuid)); user != nil {
+		return user, nil
+	}
+
+	user, err := s.storage.GetUserByUUID(ctx, uuid)
+	if err == nil && user != nil {
+		cache.Save(ctx, s.cache, userUUIDKey(uuid), user, s.ttl)
+	}
+	return user, err
+}
+
+func (s *CachedUserStorage) GetUserByAPIKey(ctx context.Context, apiKey string) (*User, error) {
+	if user := cache.Load[User](ctx, s.cache, userAPIKeyKey(apiKey)); user != nil {
+		return user, nil
+	}
+
+	user, err := s.storage.GetUserByAPIKey(ctx, apiKey)
+	if err == nil && user != nil {
+		cache.Save(ctx, s.cache, userAPIKeyKey(apiKey), user, s.ttl)
+	}
+	return user, err
+}
+
+func (s *CachedUserStorage) GetAPIKeyHash(ctx context.Context, uuid uuid.UUID) (string, error) {
+	return s.storage.GetAPIKeyHash(ctx, uuid)
+}
+
+func (s *CachedUserStorage) SetVerificationToken(ctx context.Context, uuid uuid.UUID, tokenHash string) error {
+	return s.change(ctx, uuid, func() error {
+		return s.storage.SetVerificationToken(ctx, uuid, tokenHash)
+	})
+}
+
+func (s *CachedUserStorage) Verify(ctx context.Context, uuid uuid.UUID, tokenHash string) (verifiedUser *User, err error) {
+	err = s.change(ctx, uuid, func() error {
+		verifiedUser, err = s.storage.Verify(ctx, uuid, tokenHash)
+		return err
+	})
+	return verifiedUser, err
+}
+
+func (s *CachedUserStorage) RotateAPIKey(ctx context.Context, uuid uuid.UUID, apiKey string) (rotatedUser *User, err error) {
+	err = s.change(ctx, uuid, func() error {
+		rotatedUser, err = s.storage.RotateAPIKey(ctx, uuid, apiKey)
+		return err
+	})
+	return rotatedUser, err
+}
+
+func (s *CachedUserStorage) Anonymize(ctx context.Context, uuid uuid.UUID, apiKey string) (erasedUser *User, err error) {
+	err = s.change(ctx, uuid, func() error {
+		erasedUser, err = s.storage.Anonymize(ctx, uuid, apiKey)
+		return err
+	})
+	return erasedUser, err
+}
+
+// Run fn to change a User, then remove everything cached for them. Their API key lookup is
+// cached by API key hash, so the hash is read from storage before the change (it may be
+// about to be replaced) rather than kept in the cache, where it could be evicted and leave
+// an old API key working until the lookup expires. Nothing is changed if the hash can't be read.
+func (s *CachedUserStorage) change(ctx context.Context, uuid uuid.UUID, fn func() error) error {
+	apiKey, err := s.storage.GetAPIKeyHash(ctx, uuid)
+	if err != nil {
+		return err
+	}
+	defer cache.Invalidate(ctx, s.cache, userUUIDKey(uuid), userAPIKeyKey(apiKey))
+	return fn()
+}
+
+func userUUIDKey(uuid uuid.UUID) string {
+	return "user:uuid:" + uuid.String()
+}
+
+func userAPIKeyKey(apiKey string) string {
+	return "user:api_key:" + apiKey
+}
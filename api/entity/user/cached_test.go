@@ -0,0 +1,88 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/cache"
+)
+
+func TestCachedUserLookupAndRotate(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryUserStorage()
+	storage := NewCachedUserStorage(memory, cache.NewLRU(100), time.Minute)
+
+	newUser, err := storage.Create(ctx, &User{Name: "Bob", Email: "bob@example.com", APIKey: "hash", VerificationToken: "token"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	if found, err := storage.GetUserByAPIKey(ctx, "hash"); err != nil || found == nil {
+		t.Fatalf("GetUserByAPIKey() = %v, %v, should find the created user", found, err)
+	}
+
+	// Change the user behind the cache's back, so we can tell when the cache is used.
+	newUser.Name = "Rob"
+	memory.Update(ctx, newUser)
+	if found, _ := storage.GetUserByAPIKey(ctx, "hash"); found == nil || found.Name != "Bob" {
+		t.Errorf("GetUserByAPIKey() = %v, should come from the cache", found)
+	}
+
+	// Rotating the key removes the cached lookup, so the old key stops working.
+	if _, err := storage.RotateAPIKey(ctx, newUser.UUID, "new-hash"); err != nil {
+		t.Fatalf("an error '%s' was not expected while rotating the api key", err)
+	}
+	if found, _ := storage.GetUserByAPIKey(ctx, "hash"); found != nil {
+		t.Errorf("GetUserByAPIKey() = %v, the old api key should not work", found)
+	}
+	if found, _ := storage.GetUserByAPIKey(ctx, "new-hash"); found == nil || found.Name != "Rob" {
+		t.Errorf("GetUserByAPIKey() = %v, the new api key should find the updated user", found)
+	}
+}
+
+func TestCachedUserUpdateInvalidates(t *testing.T) {
+	ctx := context.Background()
+	storage := NewCachedUserStorage(NewMemoryUserStorage(), cache.NewLRU(100), time.Minute)
+
+	newUser, _ := storage.Create(ctx, &User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	storage.GetUserByUUID(ctx, newUser.UUID)
+
+	newUser.Name = "Rob"
+	if _, err := storage.Update(ctx, newUser); err != nil {
+		t.Fatalf("an error '%s' was not expected while updating a user", err)
+	}
+	if found, _ := storage.GetUserByUUID(ctx, newUser.UUID); found == nil || found.Name != "Rob" {
+		t.Errorf("GetUserByUUID() = %v, should return the updated user", found)
+	}
+}
+
+func TestCachedUserRotateAfterEviction(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryUserStorage()
+	storage := NewCachedUserStorage(memory, cache.NewLRU(4), time.Minute)
+
+	bob, _ := storage.Create(ctx, &User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	storage.GetUserByAPIKey(ctx, "hash")
+
+	// Fill the cache past capacity with other Users, while Bob keeps using his API key so
+	// his lookup stays cached.
+	for i := 0; i < 10; i++ {
+		other, _ := storage.Create(ctx, &User{Name: "Ann", Email: fmt.Sprintf("ann%d@example.com", i), APIKey: fmt.Sprintf("hash-%d", i)})
+		storage.GetUserByUUID(ctx, other.UUID)
+		storage.GetUserByAPIKey(ctx, "hash")
+	}
+	bob.Name = "Rob"
+	memory.Update(ctx, bob)
+	if found, _ := storage.GetUserByAPIKey(ctx, "hash"); found == nil || found.Name != "Bob" {
+		t.Fatalf("GetUserByAPIKey() = %v, should still come from the cache", found)
+	}
+
+	// Rotating the key must still remove the cached lookup.
+	if _, err := storage.RotateAPIKey(ctx, bob.UUID, "new-hash"); err != nil {
+		t.Fatalf("an error '%s' was not expected while rotating the api key", err)
+	}
+	if found, _ := storage.GetUserByAPIKey(ctx, "hash"); found != nil {
+		t.Errorf("GetUserByAPIKey() = %v, the old api key should not work", found)
+	}
+}
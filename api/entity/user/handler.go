@@ -1,39 +1,47 @@
 package user
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 
 	"github.com/agnate/qlikrestapi/internal/apikey"
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/token"
+	"github.com/agnate/qlikrestapi/internal/tracing"
 	"github.com/agnate/qlikrestapi/internal/util"
 	"github.com/agnate/qlikrestapi/internal/util/baddata"
+	"github.com/agnate/qlikrestapi/internal/validation"
 )
 
 type API struct {
-	storage *UserStorage
+	storage  UserRepository
+	verifier *Verifier
 }
 
-// Create a new Users API handler.
-func New(db *sql.DB) *API {
+// Create a new Users API handler. Email verification is disabled when verifier is nil.
+func New(storage UserRepository, verifier *Verifier) *API {
 	return &API{
-		storage: NewUserStorage(db),
+		storage:  storage,
+		verifier: verifier,
 	}
 }
 
 // Retrieve a list of all Users.
 func (a *API) List(w http.ResponseWriter, r *http.Request) {
-	users, err := a.storage.List()
+	users, err := a.storage.List(r.Context())
 	if err != nil {
-		util.Status404NoAPIEndpoint(w, r, err)
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status404NoAPIEndpoint(w, r, err)
+		}
 		return
 	}
 
 	// Output list of users.
 	if err := a.outputList(users, http.StatusOK, w); err != nil {
-		util.Status500APIError(w, errors.New("could not parse data to json"))
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
 	}
 }
 
@@ -42,41 +50,192 @@ func (a *API) Create(w http.ResponseWriter, r *http.Request) {
 	// Get data from POST body.
 	userInput, err := a.getJsonBody(r)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		if !util.StatusBodyTooLarge(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
 		return
 	}
 
 	// Validate and process user input.
 	user, err := a.processUserInput(userInput)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		baddata.New400BadData(err).Render(w, r)
 		return
 	}
 
 	// Create user.
-	newUser, err := a.storage.Create(user)
+	newUser, err := a.storage.Create(r.Context(), user)
 	if err != nil {
-		baddata.New400BadData(err).Render(w)
+		if !util.StatusDatabaseError(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
 		return
 	}
 
+	// Email the user a verification link. The account is still created if this fails,
+	// since the error is on our side rather than the user's.
+	if a.verifier != nil {
+		if err := a.issueVerification(r.Context(), newUser); err != nil {
+			myCtx.GetLogger(r.Context()).Error("unable to send verification email", "user", newUser.UUID, "error", err)
+		}
+	}
+
 	// Overwrite API with raw key so the user can save it.
 	newUser.APIKey = user.RawAPIKey
 
 	// Output newly-created user.
 	if err := a.outputSingle(newUser, http.StatusCreated, w); err != nil {
-		util.Status500APIError(w, errors.New("could not parse data to json"))
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
 	}
 }
 
+// Verify a User's email address using the token from their verification link.
+func (a *API) Verify(w http.ResponseWriter, r *http.Request) {
+	if a.verifier == nil {
+		util.Status404NoAPIEndpoint(w, r, errors.New("email verification is disabled"))
+		return
+	}
+
+	// Validate the token and find out who it was issued to.
+	uuid, err := a.verifier.parseToken(r.URL.Query().Get("token"))
+	if err != nil {
+		baddata.New400BadData(err).Render(w, r)
+		return
+	}
+
+	// Mark the user as verified, which also uses up the token.
+	user, err := a.storage.Verify(r.Context(), uuid, token.Hash(r.URL.Query().Get("token")))
+	if util.StatusDatabaseError(w, r, err) {
+		return
+	}
+	if err != nil || user == nil {
+		baddata.New400BadData(errors.New("verification token is invalid or has already been used")).Render(w, r)
+		return
+	}
+
+	// Output verified user.
+	if err := a.outputSingle(user, http.StatusOK, w); err != nil {
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
+	}
+}
+
+// Return the User that owns the API key in the request headers.
+func (a *API) ReadMe(w http.ResponseWriter, r *http.Request) {
+	user, err := a.Authenticate(r)
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status401Unauthorized(w, r, err)
+		}
+		return
+	}
+
+	// Output the user's own profile.
+	if err := a.outputMe(user, http.StatusOK, w); err != nil {
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
+	}
+}
+
+// Update and return the User that owns the API key in the request headers.
+// Only the fields provided in the body are changed.
+func (a *API) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	user, err := a.Authenticate(r)
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status401Unauthorized(w, r, err)
+		}
+		return
+	}
+
+	// Get data from PATCH body.
+	userInput, err := a.getJsonBody(r)
+	if err != nil {
+		if !util.StatusBodyTooLarge(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
+		return
+	}
+
+	// Validate and apply the changes.
+	emailChanged, err := a.processUserPatch(user, userInput)
+	if err != nil {
+		baddata.New400BadData(err).Render(w, r)
+		return
+	}
+
+	// Update user.
+	updatedUser, err := a.storage.Update(r.Context(), user)
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			baddata.New400BadData(err).Render(w, r)
+		}
+		return
+	}
+
+	// A new email address needs to be verified again.
+	if emailChanged && a.verifier != nil {
+		if err := a.issueVerification(r.Context(), updatedUser); err != nil {
+			myCtx.GetLogger(r.Context()).Error("unable to send verification email", "user", updatedUser.UUID, "error", err)
+		}
+	}
+
+	// Output the updated profile.
+	if err := a.outputMe(updatedUser, http.StatusOK, w); err != nil {
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
+	}
+}
+
+// Replace the API key of the User that owns the API key in the request headers. The old
+// key stops working straight away, and the new one is only shown in this response.
+func (a *API) RotateKey(w http.ResponseWriter, r *http.Request) {
+	user, err := a.Authenticate(r)
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status401Unauthorized(w, r, err)
+		}
+		return
+	}
+
+	// Replace the stored API key hash.
+	rawAPIKey, hash := apikey.GenerateAPIKey()
+	rotatedUser, err := a.storage.RotateAPIKey(r.Context(), user.UUID, apikey.HashByteToString(hash))
+	if err != nil {
+		if !util.StatusDatabaseError(w, r, err) {
+			util.Status500APIError(w, r, err)
+		}
+		return
+	}
+
+	// Overwrite API with raw key so the user can save it.
+	rotatedUser.APIKey = rawAPIKey
+
+	// Output the profile with the new key.
+	if err := a.outputMe(rotatedUser, http.StatusOK, w); err != nil {
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
+	}
+}
+
+// Check if the User is allowed to post Messages. Unverified users are restricted
+// when email verification is enabled.
+func (a *API) CanPost(user *User) bool {
+	return a.verifier == nil || user.Verified
+}
+
 // Get user by their API key.
-func (a *API) GetUserByAPIKey(rawAPIKey string) (*User, error) {
+func (a *API) GetUserByAPIKey(ctx context.Context, rawAPIKey string) (*User, error) {
+	// Trace the lookup, since it's made for most requests and may hit the cache or the database.
+	ctx, span := tracing.Start(ctx, "user.GetUserByAPIKey")
+	user, err := a.getUserByAPIKey(ctx, rawAPIKey)
+	tracing.End(span, err)
+	return user, err
+}
+
+func (a *API) getUserByAPIKey(ctx context.Context, rawAPIKey string) (*User, error) {
 	// Hash the apiKey before searching database.
 	bytes := apikey.HashAPIKey(rawAPIKey)
 	hash := apikey.HashByteToString(bytes)
 
 	// Look up User by hashed API key.
-	user, err := a.storage.GetUserByAPIKey(hash)
+	user, err := a.storage.GetUserByAPIKey(ctx, hash)
 	if err != nil {
 		return nil, err
 	}
@@ -87,18 +246,25 @@ func (a *API) outputSingle(user *User, successHttpStatus int, w http.ResponseWri
 	return a.outputList([]*User{user}, successHttpStatus, w)
 }
 
+func (a *API) outputMe(user *User, successHttpStatus int, w http.ResponseWriter) error {
+	return a.outputJson([]*Me{{User: user, Email: user.Email}}, successHttpStatus, w)
+}
+
 func (a *API) outputList(users Users, successHttpStatus int, w http.ResponseWriter) error {
 	if len(users) <= 0 {
 		util.APIJsonHeaders(w)
 		fmt.Fprint(w, "[]")
 		return nil
 	}
+	return a.outputJson(users, successHttpStatus, w)
+}
 
+func (a *API) outputJson(data any, successHttpStatus int, w http.ResponseWriter) error {
 	// TODO: Support JSON and XML by allowing user to pass optional
 	// parameters to the API call to decide the format.
 
 	// Check JSON parsing for errors.
-	jsonData, err := json.Marshal(users)
+	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return err
 	}
@@ -112,13 +278,7 @@ func (a *API) outputList(users Users, successHttpStatus int, w http.ResponseWrit
 
 // Parse the JSON body of a request.
 func (a *API) getJsonBody(r *http.Request) (*UserInput, error) {
-	decoder := json.NewDecoder(r.Body)
-	var userInput *UserInput
-	err := decoder.Decode(&userInput)
-	if err != nil {
-		return nil, err
-	}
-	return userInput, nil
+	return util.DecodeJSONBody[UserInput](r)
 }
 
 // Convert the a UserInput object to a User and fill in missing data.
@@ -128,16 +288,17 @@ func (a *API) processUserInput(userInput *UserInput) (*User, error) {
 		return nil, errors.New("you must provide a full_name")
 	}
 
-	if len(userInput.Email) <= 0 {
-		return nil, errors.New("you must provide a valid email")
+	validEmail, err := validation.NewRuleEmail(userInput.Email)
+	if err != nil {
+		return nil, err
 	}
 
-	// TODO: Add email validation.
-
-	// Create the base Message object for database storage.
+	// Create the base User object for database storage. Users start out verified
+	// unless email verification is enabled.
 	user := &User{
-		Name:  userInput.Name,
-		Email: userInput.Email,
+		Name:     userInput.Name,
+		Email:    validEmail.Normalized,
+		Verified: a.verifier == nil,
 	}
 
 	// Generate an API key. We will return the raw key and store the hash.
@@ -147,3 +308,43 @@ func (a *API) processUserInput(userInput *UserInput) (*User, error) {
 
 	return user, nil
 }
+
+// Apply the non-empty fields of a UserInput to an existing User. Returns true if
+// the email address was changed.
+func (a *API) processUserPatch(user *User, userInput *UserInput) (bool, error) {
+	if len(userInput.Name) <= 0 && len(userInput.Email) <= 0 {
+		return false, errors.New("you must provide a full_name or email to update")
+	}
+
+	if len(userInput.Name) > 0 {
+		user.Name = userInput.Name
+	}
+
+	emailChanged := false
+	if len(userInput.Email) > 0 {
+		validEmail, err := validation.NewRuleEmail(userInput.Email)
+		if err != nil {
+			return false, err
+		}
+		emailChanged = validEmail.Normalized != user.Email
+		user.Email = validEmail.Normalized
+	}
+
+	// Changing email requires verifying the new address.
+	if emailChanged && a.verifier != nil {
+		user.Verified = false
+	}
+	return emailChanged, nil
+}
+
+// Issue a new verification token to the User and email them the link.
+func (a *API) issueVerification(ctx context.Context, user *User) error {
+	raw, hash, err := a.verifier.newToken(user.UUID)
+	if err != nil {
+		return err
+	}
+	if err := a.storage.SetVerificationToken(ctx, user.UUID, hash); err != nil {
+		return err
+	}
+	return a.verifier.send(user, raw)
+}
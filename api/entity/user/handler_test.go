@@ -0,0 +1,133 @@
+package user
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/mailer"
+)
+
+// Build a Users API backed by in-memory storage, with email verification sent to a
+// FileMailer in the returned directory.
+func newVerifyingTestAPI(t *testing.T, ttl time.Duration) (*API, string) {
+	directory := t.TempDir()
+	verifier := NewVerifier(mailer.NewFileMailer(directory), "test-secret", "http://localhost:8080", "no-reply@localhost", ttl)
+	return New(NewMemoryUserStorage(), verifier), directory
+}
+
+// Create a User through the handler and return them as output.
+func createTestUser(t *testing.T, api *API, email string) *User {
+	t.Helper()
+	w := httptest.NewRecorder()
+	api.Create(w, httptest.NewRequest(http.MethodPost, "/api/v1/users", strings.NewReader(`{"full_name": "Bob", "email": "`+email+`"}`)))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("Create() returned status %d, should be %d: %s", w.Code, http.StatusCreated, w.Body)
+	}
+	var created Users
+	if err := json.Unmarshal(w.Body.Bytes(), &created); err != nil || len(created) != 1 {
+		t.Fatalf("Create() returned %s, should be a single user", w.Body)
+	}
+	return created[0]
+}
+
+// Read the token from the verification link in the only email sent to directory.
+func readVerificationToken(t *testing.T, directory string) string {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(directory, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("found %d emails, should be 1", len(files))
+	}
+	file, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if link, err := url.Parse(strings.TrimSpace(scanner.Text())); err == nil && link.Query().Has("token") {
+			return link.Query().Get("token")
+		}
+	}
+	t.Fatal("the email should contain a verification link")
+	return ""
+}
+
+func serveVerify(api *API, token string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	api.Verify(w, httptest.NewRequest(http.MethodGet, "/api/v1/users/verify?token="+url.QueryEscape(token), nil))
+	return w
+}
+
+func TestHandlerVerify(t *testing.T) {
+	api, directory := newVerifyingTestAPI(t, time.Hour)
+	created := createTestUser(t, api, "bob@example.com")
+	if created.Verified {
+		t.Fatal("a new user should not be verified yet")
+	}
+	if api.CanPost(created) {
+		t.Error("an unverified user should not be allowed to post")
+	}
+
+	// Follow the link from the email.
+	token := readVerificationToken(t, directory)
+	w := serveVerify(api, token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Verify() returned status %d, should be %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	verified, _ := api.storage.GetUserByUUID(context.Background(), created.UUID)
+	if verified == nil || !verified.Verified || !api.CanPost(verified) {
+		t.Fatal("the user should be verified and allowed to post")
+	}
+
+	// The token can only be used once.
+	if w := serveVerify(api, token); w.Code != http.StatusBadRequest {
+		t.Errorf("Verify() with a used token returned status %d, should be %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerVerifyExpired(t *testing.T) {
+	api, directory := newVerifyingTestAPI(t, -time.Minute)
+	created := createTestUser(t, api, "bob@example.com")
+
+	w := serveVerify(api, readVerificationToken(t, directory))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "expired") {
+		t.Fatalf("Verify() with an expired token returned status %d (%s), should be %d", w.Code, w.Body, http.StatusBadRequest)
+	}
+	if user, _ := api.storage.GetUserByUUID(context.Background(), created.UUID); user == nil || user.Verified {
+		t.Error("the user should still be unverified")
+	}
+}
+
+func TestHandlerVerifyInvalid(t *testing.T) {
+	api, directory := newVerifyingTestAPI(t, time.Hour)
+	createTestUser(t, api, "bob@example.com")
+
+	// Tamper with the signed token.
+	token := readVerificationToken(t, directory)
+	if w := serveVerify(api, token+"x"); w.Code != http.StatusBadRequest {
+		t.Errorf("Verify() with a tampered token returned status %d, should be %d", w.Code, http.StatusBadRequest)
+	}
+	if w := serveVerify(api, ""); w.Code != http.StatusBadRequest {
+		t.Errorf("Verify() without a token returned status %d, should be %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestHandlerVerifyDisabled(t *testing.T) {
+	api := New(NewMemoryUserStorage(), nil)
+	if w := serveVerify(api, "anything"); w.Code != http.StatusNotFound {
+		t.Errorf("Verify() with verification disabled returned status %d, should be %d", w.Code, http.StatusNotFound)
+	}
+	if !api.CanPost(&User{}) {
+		t.Error("every user should be allowed to post when verification is disabled")
+	}
+}
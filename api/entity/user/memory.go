@@ -0,0 +1,194 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Thread-safe, in-memory User storage with the same behaviour as UserStorage.
+// Useful for tests and for running the API without a database. Contexts are ignored since
+// nothing blocks for long.
+type MemoryUserStorage struct {
+	mu    sync.RWMutex
+	users map[uuid.UUID]*User
+	order []uuid.UUID // Insertion order, used when listing
+}
+
+// Create a new, empty in-memory User storage container/service.
+func NewMemoryUserStorage() *MemoryUserStorage {
+	return &MemoryUserStorage{
+		users: make(map[uuid.UUID]*User),
+	}
+}
+
+func (s *MemoryUserStorage) List(ctx context.Context) (Users, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	users := make(Users, 0, len(s.order))
+	for _, id := range s.order {
+		users = append(users, stripAPIKey(s.users[id]))
+	}
+	return users, nil
+}
+
+func (s *MemoryUserStorage) Create(ctx context.Context, user *User) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.checkUnique(uuid.UUID{}, user.Email, user.APIKey); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	newUser := &User{
+		UUID:       uuid.New(),
+		Email:      user.Email,
+		APIKey:     user.APIKey,
+		LastAccess: now,
+		CreateDate: now,
+		Name:       user.Name,
+		Verified:   user.Verified,
+	}
+	s.users[newUser.UUID] = newUser
+	s.order = append(s.order, newUser.UUID)
+
+	// Like UserStorage, the API key hash is only returned on creation.
+	copied := *newUser
+	return &copied, nil
+}
+
+func (s *MemoryUserStorage) Update(ctx context.Context, user *User) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, ok := s.users[user.UUID]
+	if !ok {
+		return nil, errors.New("no rows updated")
+	}
+	if err := s.checkUnique(user.UUID, user.Email, ""); err != nil {
+		return nil, err
+	}
+
+	existing.Name = user.Name
+	existing.Email = user.Email
+	existing.Verified = user.Verified
+	return stripAPIKey(existing), nil
+}
+
+func (s *MemoryUserStorage) GetUserByUUID(ctx context.Context, uuid uuid.UUID) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if user, ok := s.users[uuid]; ok {
+		return stripAPIKey(user), nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryUserStorage) GetUserByAPIKey(ctx context.Context, apiKey string) (*User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.APIKey == apiKey {
+			return stripAPIKey(user), nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *MemoryUserStorage) GetAPIKeyHash(ctx context.Context, uuid uuid.UUID) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if user, ok := s.users[uuid]; ok {
+		return user.APIKey, nil
+	}
+	return "", nil
+}
+
+func (s *MemoryUserStorage) SetVerificationToken(ctx context.Context, uuid uuid.UUID, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user, ok := s.users[uuid]; ok {
+		user.VerificationToken = tokenHash
+	}
+	return nil
+}
+
+func (s *MemoryUserStorage) Verify(ctx context.Context, uuid uuid.UUID, tokenHash string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[uuid]
+	if !ok || len(user.VerificationToken) <= 0 || user.VerificationToken != tokenHash {
+		return nil, errors.New("no rows updated")
+	}
+
+	user.Verified = true
+	user.VerificationToken = ""
+	return stripAPIKey(user), nil
+}
+
+func (s *MemoryUserStorage) Anonymize(ctx context.Context, uuid uuid.UUID, apiKey string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[uuid]
+	if !ok {
+		return nil, errors.New("no rows updated")
+	}
+
+	user.Name = ""
+	user.Email = uuid.String() + "@erased.invalid"
+	user.APIKey = apiKey
+	user.Verified = false
+	user.VerificationToken = ""
+	return stripAPIKey(user), nil
+}
+
+func (s *MemoryUserStorage) RotateAPIKey(ctx context.Context, uuid uuid.UUID, apiKey string) (*User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.users[uuid]
+	if !ok {
+		return nil, errors.New("no rows updated")
+	}
+	if err := s.checkUnique(uuid, "", apiKey); err != nil {
+		return nil, err
+	}
+
+	user.APIKey = apiKey
+	return stripAPIKey(user), nil
+}
+
+// Enforce the same unique constraints as the database, ignoring the User being changed.
+// Empty values are not checked. Must be called with the lock held.
+func (s *MemoryUserStorage) checkUnique(self uuid.UUID, email string, apiKey string) error {
+	for id, user := range s.users {
+		if id == self {
+			continue
+		}
+		if len(email) > 0 && user.Email == email {
+			return errors.New("duplicate key value violates unique constraint \"unique_email\"")
+		}
+		if len(apiKey) > 0 && user.APIKey == apiKey {
+			return errors.New("duplicate key value violates unique constraint \"unique_api_key\"")
+		}
+	}
+	return nil
+}
+
+// Return a copy of the User with the API key hash removed.
+func stripAPIKey(user *User) *User {
+	copied := *user
+	copied.APIKey = ""
+	return &copied
+}
@@ -0,0 +1,55 @@
+package user
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryUserCreateStripsAPIKey(t *testing.T) {
+	storage := NewMemoryUserStorage()
+
+	newUser, err := storage.Create(context.Background(), &User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	if newUser.APIKey != "hash" {
+		t.Errorf("Create() should return the API key hash")
+	}
+
+	found, err := storage.GetUserByAPIKey(context.Background(), "hash")
+	if err != nil || found == nil || found.UUID != newUser.UUID {
+		t.Fatalf("GetUserByAPIKey() = %v, %v, should find the created user", found, err)
+	}
+	if found.APIKey != "" {
+		t.Errorf("GetUserByAPIKey() should strip the API key hash")
+	}
+}
+
+func TestMemoryUserUniqueEmail(t *testing.T) {
+	storage := NewMemoryUserStorage()
+	storage.Create(context.Background(), &User{Name: "Bob", Email: "bob@example.com", APIKey: "hash1"})
+	other, _ := storage.Create(context.Background(), &User{Name: "Rob", Email: "rob@example.com", APIKey: "hash2"})
+
+	if _, err := storage.Create(context.Background(), &User{Name: "Bobby", Email: "bob@example.com", APIKey: "hash3"}); err == nil {
+		t.Errorf("creating a user with a duplicate email should fail")
+	}
+
+	other.Email = "bob@example.com"
+	if _, err := storage.Update(context.Background(), other); err == nil {
+		t.Errorf("updating a user to a duplicate email should fail")
+	}
+}
+
+func TestMemoryUserVerifyOnce(t *testing.T) {
+	storage := NewMemoryUserStorage()
+	newUser, _ := storage.Create(context.Background(), &User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	storage.SetVerificationToken(context.Background(), newUser.UUID, "token-hash")
+
+	verified, err := storage.Verify(context.Background(), newUser.UUID, "token-hash")
+	if err != nil || !verified.Verified {
+		t.Fatalf("Verify() = %v, %v, should verify the user", verified, err)
+	}
+	if _, err := storage.Verify(context.Background(), newUser.UUID, "token-hash"); err == nil {
+		t.Errorf("a verification token should only work once")
+	}
+}
@@ -0,0 +1,19 @@
+package user
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons an API key check failed, used to label AuthFailures.
+const (
+	AuthMissing   = "missing"   // No API key was sent
+	AuthInvalid   = "invalid"   // No User has the API key
+	AuthForbidden = "forbidden" // The API key belongs to a different User
+)
+
+// API key checks that failed, by reason. Served on /metrics.
+var AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "qlik_auth_failures_total",
+	Help: "API key checks that failed, by reason.",
+}, []string{"reason"})
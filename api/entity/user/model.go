@@ -12,13 +12,21 @@ type UserInput struct {
 }
 
 type User struct {
-	UUID       uuid.UUID `json:"user_id"`
-	Email      string    `json:"-"`
-	APIKey     string    `json:"api_key"`
-	LastAccess time.Time `json:"last_access"`
-	CreateDate time.Time `json:"-"`
-	Name       string    `json:"name"`
-	RawAPIKey  string    `json:"-"`
+	UUID              uuid.UUID `json:"user_id"`
+	Email             string    `json:"-"`
+	APIKey            string    `json:"api_key"`
+	LastAccess        time.Time `json:"last_access"`
+	CreateDate        time.Time `json:"-"`
+	Name              string    `json:"name"`
+	Verified          bool      `json:"verified"`
+	VerificationToken string    `json:"-"` // Hash of the outstanding verification token, if any
+	RawAPIKey         string    `json:"-"`
 }
 
 type Users []*User
+
+// The authenticated User's own profile, which also includes their email.
+type Me struct {
+	*User
+	Email string `json:"email"`
+}
@@ -0,0 +1,43 @@
+package user
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+// Storage operations for Users. Implemented by UserStorage (SQL database) and
+// MemoryUserStorage (in-memory, for tests and local development).
+//
+// Emails and API key hashes are unique. The API key hash is only returned by Create and
+// GetAPIKeyHash; every other lookup strips it off. Every method takes the request context,
+// so work is abandoned once the request ends.
+type UserRepository interface {
+	// Retrieve a list of Users.
+	List(ctx context.Context) (Users, error)
+	// Create a new User and retrieve them.
+	Create(ctx context.Context, user *User) (*User, error)
+	// Update an existing User's profile and retrieve them.
+	Update(ctx context.Context, user *User) (*User, error)
+	// Get a User by their UUID. Returns nil if not found.
+	GetUserByUUID(ctx context.Context, uuid uuid.UUID) (*User, error)
+	// Get a User by their hashed API key. Returns nil if not found.
+	GetUserByAPIKey(ctx context.Context, apiKey string) (*User, error)
+	// Get the hash of a User's API key (ex: to find their cached API key lookup). Returns an
+	// empty string if not found.
+	GetAPIKeyHash(ctx context.Context, uuid uuid.UUID) (string, error)
+	// Store the hash of a newly issued verification token, replacing any previous one.
+	SetVerificationToken(ctx context.Context, uuid uuid.UUID, tokenHash string) error
+	// Mark a User as verified if the token hash matches the outstanding one, using it up.
+	Verify(ctx context.Context, uuid uuid.UUID, tokenHash string) (*User, error)
+	// Replace a User's API key hash, so the old API key stops working.
+	RotateAPIKey(ctx context.Context, uuid uuid.UUID, apiKey string) (*User, error)
+	// Remove a User's personal data while keeping the row, replacing their API key hash.
+	Anonymize(ctx context.Context, uuid uuid.UUID, apiKey string) (*User, error)
+}
+
+var (
+	_ UserRepository = (*UserStorage)(nil)
+	_ UserRepository = (*MemoryUserStorage)(nil)
+	_ UserRepository = (*CachedUserStorage)(nil)
+)
@@ -1,93 +1,172 @@
 package user
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/database"
+	"github.com/google/uuid"
 )
 
+// Columns read from the users table and the User fields they are scanned into.
+var userColumns = database.Columns[User]{
+	{Name: "uuid", Field: func(u *User) any { return &u.UUID }},
+	{Name: "email", Field: func(u *User) any { return &u.Email }},
+	{Name: "api_key", Field: func(u *User) any { return &u.APIKey }},
+	{Name: "last_access", Field: func(u *User) any { return &u.LastAccess }},
+	{Name: "create_date", Field: func(u *User) any { return &u.CreateDate }},
+	{Name: "full_name", Field: func(u *User) any { return &u.Name }},
+	{Name: "verified", Field: func(u *User) any { return &u.Verified }},
+	{Name: "verification_token", Field: func(u *User) any { return &u.VerificationToken }},
+}
+
+var selectUsers = "SELECT " + userColumns.String() + " FROM users"
+
 type UserStorage struct {
-	db *sql.DB
+	database.Binding
 }
 
 // Create a new User storage container/service.
 func NewUserStorage(db *sql.DB) *UserStorage {
 	return &UserStorage{
-		db: db,
+		Binding: database.NewBinding(db),
 	}
 }
 
+// Get a copy of the storage that runs everything inside an existing transaction, so
+// it can be shared with other storage (ex: MessageStorage) in a multi-entity operation.
+// See [database.Binding.WithTx].
+func (s *UserStorage) WithTx(tx *sql.Tx) *UserStorage {
+	return &UserStorage{Binding: s.Binding.WithTx(tx)}
+}
+
+// Get a copy of the storage that aborts any query taking longer than timeout.
+func (s *UserStorage) WithQueryTimeout(timeout time.Duration) *UserStorage {
+	return &UserStorage{Binding: s.Binding.WithQueryTimeout(timeout)}
+}
+
+// Get a copy of the storage that sends List queries to the read replicas. Lookups of a
+// single User always go to the primary. See [database.Binding.WithReplicas].
+func (s *UserStorage) WithReplicas(replicas ...*sql.DB) *UserStorage {
+	return &UserStorage{Binding: s.Binding.WithReplicas(replicas...)}
+}
+
 // Retrieve a list of Users.
-func (s *UserStorage) List() (Users, error) {
-	return s.scanUsers("SELECT * FROM users")
+func (s *UserStorage) List(ctx context.Context) (Users, error) {
+	return s.scanUsers(ctx, "users.list", selectUsers)
 }
 
 // Create a new User and retrieve them.
-func (s *UserStorage) Create(user *User) (*User, error) {
-	// Create the new User.
-	_, err := s.db.Exec("INSERT INTO users(full_name, email, api_key) VALUES($1, $2, $3)",
-		user.Name, user.Email, user.APIKey)
+func (s *UserStorage) Create(ctx context.Context, user *User) (*User, error) {
+	// The UUID is generated here since not every database can generate one.
+	return s.writeUser(ctx, "users.create", "INSERT INTO users(uuid, full_name, email, api_key, verified) VALUES($1, $2, $3, $4, $5)",
+		uuid.New(), user.Name, user.Email, user.APIKey, user.Verified)
+}
+
+// Update an existing User's profile and retrieve them.
+func (s *UserStorage) Update(ctx context.Context, user *User) (*User, error) {
+	updatedUser, err := s.writeUser(ctx, "users.update", "UPDATE users SET full_name = $1, email = $2, verified = $3 WHERE uuid = $4",
+		user.Name, user.Email, user.Verified, user.UUID)
 	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
 		return nil, err
 	}
-	// Retrieve the new User.
-	newUser, err := s.getNewUser(user)
+	return stripAPIKey(updatedUser), nil
+}
+
+// Replace a User's API key hash, so the old API key stops working.
+func (s *UserStorage) RotateAPIKey(ctx context.Context, uuid uuid.UUID, apiKey string) (*User, error) {
+	rotatedUser, err := s.writeUser(ctx, "users.rotate_api_key", "UPDATE users SET api_key = $1 WHERE uuid = $2", apiKey, uuid)
+	if err != nil {
+		return nil, err
+	}
+	return stripAPIKey(rotatedUser), nil
+}
+
+// Remove a User's personal data while keeping the row, so Messages that reference them
+// (including last_updated_by) stay valid. The email and API key are replaced with unique
+// placeholders since both columns must be unique, and the old API key stops working.
+func (s *UserStorage) Anonymize(ctx context.Context, uuid uuid.UUID, apiKey string) (*User, error) {
+	erasedUser, err := s.writeUser(ctx, "users.anonymize", "UPDATE users SET full_name = $1, email = $2, api_key = $3, verified = $4, verification_token = $5 WHERE uuid = $6",
+		"", uuid.String()+"@erased.invalid", apiKey, false, "", uuid)
 	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
 		return nil, err
 	}
-	return newUser, nil
+	return stripAPIKey(erasedUser), nil
 }
 
 // Get a User by their API key.
-func (s *UserStorage) GetUserByAPIKey(apiKey string) (*User, error) {
-	users, err := s.scanUsers("SELECT * FROM users WHERE api_key = $1 LIMIT 1", apiKey)
+func (s *UserStorage) GetUserByAPIKey(ctx context.Context, apiKey string) (*User, error) {
+	// Use the primary, since a new API key may not have reached the replicas yet.
+	ctx = database.WithPrimary(ctx)
+	users, err := s.scanUsers(ctx, "users.get_by_api_key", selectUsers+" WHERE api_key = $1 LIMIT 1", apiKey)
 	if err == nil && len(users) > 0 {
 		return users[0], nil
 	}
 	return nil, err
 }
 
-func (s *UserStorage) getNewUser(user *User) (*User, error) {
-	users, err := s.scanUsersIncludeAPIKey("SELECT * FROM users WHERE full_name = $1 AND email = $2 AND api_key = $3 LIMIT 1",
-		user.Name, user.Email, user.APIKey)
+// Get a User by their UUID.
+func (s *UserStorage) GetUserByUUID(ctx context.Context, uuid uuid.UUID) (*User, error) {
+	// Use the primary, since the User may have only just been created or changed.
+	ctx = database.WithPrimary(ctx)
+	users, err := s.scanUsers(ctx, "users.get_by_uuid", selectUsers+" WHERE uuid = $1 LIMIT 1", uuid)
 	if err == nil && len(users) > 0 {
 		return users[0], nil
 	}
 	return nil, err
 }
 
-func (s *UserStorage) scanUsers(query string, queryParams ...any) (Users, error) {
-	users, err := s.scanUsersIncludeAPIKey(query, queryParams...)
-	if err != nil {
-		return users, err
+// Get the hash of a User's API key.
+func (s *UserStorage) GetAPIKeyHash(ctx context.Context, uuid uuid.UUID) (string, error) {
+	// Use the primary, since the key may have only just been rotated.
+	ctx = database.WithPrimary(ctx)
+	users, err := database.Select(ctx, s.Binding, "users.get_api_key_hash", userColumns, selectUsers+" WHERE uuid = $1 LIMIT 1", uuid)
+	if err != nil || len(users) == 0 {
+		return "", err
 	}
+	return users[0].APIKey, nil
+}
 
-	// Strip off API key hash.
-	for _, user := range users {
-		user.APIKey = ""
+// Store the hash of a newly issued verification token, replacing any previous one.
+func (s *UserStorage) SetVerificationToken(ctx context.Context, uuid uuid.UUID, tokenHash string) error {
+	return s.Exec(ctx, "users.set_verification_token", "UPDATE users SET verification_token = $1 WHERE uuid = $2", tokenHash, uuid)
+}
+
+// Mark a User as verified if the token hash matches the outstanding one. The token is
+// cleared in the same statement so it can only be used once.
+func (s *UserStorage) Verify(ctx context.Context, uuid uuid.UUID, tokenHash string) (*User, error) {
+	verifiedUser, err := s.writeUser(ctx, "users.verify", "UPDATE users SET verified = $1, verification_token = $2 "+
+		"WHERE uuid = $3 AND verification_token = $4 AND verification_token <> $2",
+		true, "", uuid, tokenHash)
+	if err != nil {
+		return nil, err
 	}
-	return users, nil
+	return stripAPIKey(verifiedUser), nil
 }
 
-func (s *UserStorage) scanUsersIncludeAPIKey(query string, queryParams ...any) (Users, error) {
-	users := make([]*User, 0)
+// Write a User with [database.Write], traced as name (ex: "users.update"). The row is
+// returned with its API key hash.
+func (s *UserStorage) writeUser(ctx context.Context, name string, query string, queryParams ...any) (*User, error) {
+	user, err := database.Write(ctx, s.Binding, name, userColumns, query, queryParams...)
+
+	// No row is returned when the WHERE clause didn't match.
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errors.New("no rows updated")
+	}
+	return user, err
+}
 
-	rows, err := s.db.Query(query, queryParams...)
+func (s *UserStorage) scanUsers(ctx context.Context, name string, query string, queryParams ...any) (Users, error) {
+	users, err := database.Select(ctx, s.Binding, name, userColumns, query, queryParams...)
 	if err != nil {
-		// TODO: Database errors should have better logging so they can be monitored and fixed.
-		log.Println(err)
 		return users, err
 	}
 
-	for rows.Next() {
-		user := &User{}
-		// TODO: Stop making assumptions about how data will be returned, since this fails if the db schema changes.
-		rows.Scan(&user.UUID, &user.Email, &user.APIKey, &user.LastAccess, &user.CreateDate, &user.Name)
-		users = append(users, user)
+	// Strip off API key hash.
+	for _, user := range users {
+		user.APIKey = ""
 	}
-
 	return users, nil
 }
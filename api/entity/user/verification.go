@@ -0,0 +1,73 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/mailer"
+	"github.com/agnate/qlikrestapi/internal/token"
+	"github.com/google/uuid"
+)
+
+// Issues email verification tokens and sends them to new Users through a Mailer.
+type Verifier struct {
+	mailer mailer.Mailer
+	secret []byte
+	url    string
+	from   string
+	ttl    time.Duration
+}
+
+// Create a new Verifier.
+//
+// # Parameters
+//   - m: Mailer used to deliver the verification link
+//   - secret: Key used to sign tokens (keep this private)
+//   - baseURL: Public URL of the API used to build the link (ex: http://localhost:8080)
+//   - from: Address the email is sent from
+//   - ttl: How long a token remains valid
+func NewVerifier(m mailer.Mailer, secret string, baseURL string, from string, ttl time.Duration) *Verifier {
+	return &Verifier{
+		mailer: m,
+		secret: []byte(secret),
+		url:    baseURL,
+		from:   from,
+		ttl:    ttl,
+	}
+}
+
+// Create a signed, single-use token for the User. Returns the raw token to send and
+// the hash to store.
+func (v *Verifier) newToken(uuid uuid.UUID) (raw string, hash string, err error) {
+	raw, err = token.Sign(v.secret, uuid.String(), time.Now().Add(v.ttl))
+	if err != nil {
+		return "", "", err
+	}
+	return raw, token.Hash(raw), nil
+}
+
+// Check the token signature and expiry, and return the UUID of the User it was issued to.
+func (v *Verifier) parseToken(raw string) (uuid.UUID, error) {
+	subject, err := token.Verify(v.secret, raw, time.Now())
+	if errors.Is(err, token.ErrExpired) {
+		return uuid.UUID{}, errors.New("verification token has expired")
+	}
+	if err != nil {
+		return uuid.UUID{}, errors.New("invalid verification token")
+	}
+	return uuid.Parse(subject)
+}
+
+// Email the verification link to the User.
+func (v *Verifier) send(user *User, raw string) error {
+	link := fmt.Sprintf("%s/api/v1/users/verify?token=%s", v.url, url.QueryEscape(raw))
+	return v.mailer.Send(&mailer.Message{
+		From:    v.from,
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease verify your email address by visiting the link below:\n\n%s\n\n"+
+			"This link expires in %s.\n", user.Name, link, v.ttl),
+	})
+}
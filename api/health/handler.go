@@ -0,0 +1,149 @@
+// Liveness and readiness endpoints for Docker and the orchestrator to probe.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/migrator"
+	"github.com/agnate/qlikrestapi/internal/util"
+)
+
+// A named check of something the API needs in order to serve requests. Run returns nil
+// when it's healthy. The error is shown to anyone calling /readyz, so it must not contain
+// anything sensitive (ex: connection strings).
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+type API struct {
+	checks       []Check
+	timeout      time.Duration
+	shuttingDown atomic.Bool
+}
+
+// Create a new Health API. Every check must pass within timeout for the API to be ready.
+func New(timeout time.Duration, checks ...Check) *API {
+	return &API{
+		checks:  checks,
+		timeout: timeout,
+	}
+}
+
+// Mark the API as not ready, so the orchestrator stops sending it traffic while in-flight
+// requests finish.
+func (a *API) ShutDown() {
+	a.shuttingDown.Store(true)
+}
+
+// Report that the process is alive. Doesn't check any dependencies, since restarting the
+// API won't fix them.
+func (a *API) Live(w http.ResponseWriter, r *http.Request) {
+	a.output(&Status{Status: StatusUp}, http.StatusOK, w, r)
+}
+
+// Report whether the API can serve requests, with the status of each component.
+func (a *API) Ready(w http.ResponseWriter, r *http.Request) {
+	status := &Status{Status: StatusUp, Components: make(map[string]Component)}
+
+	// Stop being ready as soon as shutdown starts.
+	server := Component{Status: StatusUp}
+	if a.shuttingDown.Load() {
+		server = Component{Status: StatusDown, Error: "shutting down"}
+	}
+	status.Components["server"] = server
+
+	// Run every check at the same time, so a slow one doesn't hold up the others.
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range a.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			component := Component{Status: StatusUp}
+			if err := check.Run(ctx); err != nil {
+				component = Component{Status: StatusDown, Error: err.Error()}
+			}
+			mu.Lock()
+			status.Components[check.Name] = component
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+
+	httpStatus := http.StatusOK
+	for _, component := range status.Components {
+		if component.Status != StatusUp {
+			status.Status = StatusDown
+			httpStatus = http.StatusServiceUnavailable
+		}
+	}
+	a.output(status, httpStatus, w, r)
+}
+
+func (a *API) output(status *Status, httpStatus int, w http.ResponseWriter, r *http.Request) {
+	jsonData, err := json.Marshal(status)
+	if err != nil {
+		util.Status500APIError(w, r, errors.New("could not parse data to json"))
+		return
+	}
+
+	// Probes must always see the current status.
+	w.Header().Set("Cache-Control", "no-store")
+	util.APIJsonHeaders(w)
+	w.WriteHeader(httpStatus)
+	w.Write(jsonData)
+}
+
+// Check that the database can be reached.
+func DatabaseCheck(name string, db *sql.DB) Check {
+	return Check{
+		Name: name,
+		Run: func(ctx context.Context) error {
+			if err := db.PingContext(ctx); err != nil {
+				// The error may include connection details, so only log it.
+				myCtx.GetLogger(ctx).Warn("health check failed", "check", name, "error", err)
+				return errors.New("unreachable")
+			}
+			return nil
+		},
+	}
+}
+
+// Check that every migration the API was built with has been applied.
+func MigrationCheck(m *migrator.Migrator, db *sql.DB) Check {
+	return Check{
+		Name: "migrations",
+		Run: func(ctx context.Context) error {
+			latest, err := m.Latest()
+			if err != nil {
+				myCtx.GetLogger(ctx).Warn("health check failed", "check", "migrations", "error", err)
+				return errors.New("unable to read migrations")
+			}
+			version, dirty, err := m.Version(ctx, db)
+			if err != nil {
+				myCtx.GetLogger(ctx).Warn("health check failed", "check", "migrations", "error", err)
+				return errors.New("unable to read version")
+			}
+			if dirty {
+				return fmt.Errorf("version %d is dirty", version)
+			}
+			if version != latest {
+				return fmt.Errorf("at version %d, expected %d", version, latest)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/migrator"
+	_ "modernc.org/sqlite"
+)
+
+func getStatus(t *testing.T, handler http.HandlerFunc) (int, *Status) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var status Status
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("an error '%s' was not expected when decoding %q", err, w.Body.String())
+	}
+	return w.Code, &status
+}
+
+func TestLive(t *testing.T) {
+	failing := Check{Name: "database", Run: func(ctx context.Context) error { return errors.New("unreachable") }}
+	api := New(time.Second, failing)
+
+	code, status := getStatus(t, api.Live)
+	if code != http.StatusOK || status.Status != StatusUp {
+		t.Errorf("Live() = %d %+v, should not depend on the checks", code, status)
+	}
+}
+
+func TestReady(t *testing.T) {
+	passing := Check{Name: "database", Run: func(ctx context.Context) error { return nil }}
+	failing := Check{Name: "migrations", Run: func(ctx context.Context) error { return errors.New("at version 1, expected 5") }}
+
+	code, status := getStatus(t, New(time.Second, passing).Ready)
+	if code != http.StatusOK || status.Status != StatusUp || status.Components["database"].Status != StatusUp {
+		t.Errorf("Ready() = %d %+v, want 200 and up", code, status)
+	}
+
+	code, status = getStatus(t, New(time.Second, passing, failing).Ready)
+	if code != http.StatusServiceUnavailable || status.Status != StatusDown {
+		t.Errorf("Ready() = %d %+v, want 503 and down", code, status)
+	}
+	if got := status.Components["migrations"]; got.Status != StatusDown || got.Error != "at version 1, expected 5" {
+		t.Errorf("migrations component = %+v, should report the error", got)
+	}
+}
+
+func TestReadyTimeout(t *testing.T) {
+	slow := Check{Name: "database", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	code, status := getStatus(t, New(10*time.Millisecond, slow).Ready)
+	if code != http.StatusServiceUnavailable || status.Components["database"].Status != StatusDown {
+		t.Errorf("Ready() = %d %+v, a check that times out should be down", code, status)
+	}
+}
+
+func TestReadyShuttingDown(t *testing.T) {
+	api := New(time.Second)
+	api.ShutDown()
+
+	code, status := getStatus(t, api.Ready)
+	if code != http.StatusServiceUnavailable || status.Components["server"].Status != StatusDown {
+		t.Errorf("Ready() = %d %+v, should not be ready while shutting down", code, status)
+	}
+}
+
+func TestMigrationCheckSQLite(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", "file:"+dbName)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	defer db.Close()
+	m := migrator.New("", dbName, "sqlite")
+	check := MigrationCheck(m, db)
+
+	if err := m.Up(db, 1); err != nil {
+		t.Fatalf("Up(1) = %v", err)
+	}
+	if err := check.Run(context.Background()); err == nil || err.Error() != "at version 1, expected 5" {
+		t.Errorf("Run() = %v, should report the missing migration", err)
+	}
+
+	if err := m.Run(db); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if err := check.Run(context.Background()); err != nil {
+		t.Errorf("Run() = %v, should pass once every migration is applied", err)
+	}
+}
@@ -0,0 +1,16 @@
+package health
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+type Status struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components,omitempty"`
+}
+
+type Component struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
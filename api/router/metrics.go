@@ -0,0 +1,67 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Requests are labelled by route pattern rather than path, so UUIDs and dates in the
+// path don't create a new series for every User and Message.
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "qlik_http_requests_total",
+		Help: "HTTP requests served, by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "qlik_http_request_duration_seconds",
+		Help:    "Time taken to serve HTTP requests, by route pattern, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+)
+
+// Label used for requests that didn't match any route (404s and 405s).
+const unmatchedRoute = "unmatched"
+
+func observeRequest(pattern string, r *http.Request, status int, start time.Time) {
+	if len(pattern) == 0 {
+		pattern = unmatchedRoute
+	}
+	method := r.Method
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions:
+	default:
+		// Any other method is client supplied, so don't let it add new series.
+		method = "other"
+	}
+	requestsTotal.WithLabelValues(pattern, method, strconv.Itoa(status)).Inc()
+	requestDuration.WithLabelValues(pattern, method, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+}
+
+// Keeps the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if !sr.wroteHeader {
+		sr.status = status
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Allow http.ResponseController to reach the underlying ResponseWriter.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+)
+
+// Access log formats.
+const (
+	AccessLogCommon   = "common"   // NCSA Common Log Format
+	AccessLogCombined = "combined" // Common Log Format with the referer and user agent
+	AccessLogJSON     = "json"     // One JSON object per request
+)
+
+// Write a line to w for every request once it has been served, in the given format.
+// Unlike the application logs, every request is written regardless of the log level.
+func AccessLog(w io.Writer, format string) func(http.Handler) http.Handler {
+	// The logger makes sure lines from concurrent requests aren't interleaved.
+	logger := log.New(w, "", 0)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			recorder := newResponseRecorder(w)
+			next.ServeHTTP(recorder, r)
+
+			entry := accessLogEntry{
+				Time:      start,
+				RequestID: myCtx.GetRequestID(r.Context()),
+				ClientIP:  myCtx.GetClientIP(r.Context()),
+				Method:    r.Method,
+				URI:       r.RequestURI,
+				Proto:     r.Proto,
+				Status:    recorder.status,
+				Bytes:     recorder.bytes,
+				Duration:  time.Since(start).Seconds(),
+				Referer:   r.Referer(),
+				UserAgent: r.UserAgent(),
+			}
+			if len(entry.ClientIP) == 0 {
+				entry.ClientIP = clientIP(r, nil)
+			}
+			logger.Print(entry.format(format))
+		})
+	}
+}
+
+type accessLogEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	ClientIP  string    `json:"client_ip"`
+	Method    string    `json:"method"`
+	URI       string    `json:"uri"`
+	Proto     string    `json:"proto"`
+	Status    int       `json:"status"`
+	Bytes     int       `json:"bytes"`
+	Duration  float64   `json:"duration_seconds"`
+	Referer   string    `json:"referer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+func (e *accessLogEntry) format(format string) string {
+	if format == AccessLogJSON {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err.Error()
+		}
+		return string(data)
+	}
+
+	// Common: host ident authuser [date] "request" status bytes
+	size := "-"
+	if e.Bytes > 0 {
+		size = strconv.Itoa(e.Bytes)
+	}
+	line := fmt.Sprintf("%s - - [%s] %s %d %s", e.ClientIP, e.Time.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(e.Method+" "+e.URI+" "+e.Proto), e.Status, size)
+	if format == AccessLogCombined {
+		line += fmt.Sprintf(" %s %s", quoteOrDash(e.Referer), quoteOrDash(e.UserAgent))
+	}
+	return line
+}
+
+func quoteOrDash(s string) string {
+	if len(s) == 0 {
+		return `"-"`
+	}
+	return strconv.Quote(s)
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func serveAccessLog(t *testing.T, format string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	handler := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}), RequestID, ClientIP(nil), AccessLog(&buf, format))
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/messages?x=1", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+	r.Header.Set("User-Agent", "curl/8.0")
+	r.Header.Set("X-Request-ID", "abc-123")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	return buf.String()
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	want := regexp.MustCompile(`^203\.0\.113\.7 - - \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "POST /api/v1/messages\?x=1 HTTP/1\.1" 201 5 "-" "curl/8\.0"\n$`)
+	if got := serveAccessLog(t, AccessLogCombined); !want.MatchString(got) {
+		t.Errorf("access log = %q, want a combined log line", got)
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	var entry accessLogEntry
+	if err := json.Unmarshal([]byte(serveAccessLog(t, AccessLogJSON)), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.RequestID != "abc-123" || entry.ClientIP != "203.0.113.7" || entry.Status != http.StatusCreated || entry.Bytes != 5 {
+		t.Errorf("access log entry = %+v", entry)
+	}
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+)
+
+// Parse a list of IP addresses and CIDR ranges (ex: 10.0.0.0/8) of trusted proxies.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, value := range values {
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy `%s`, must be an IP address or CIDR range", value)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// Add the IP address of the client to the request context. When the request comes from a
+// trusted proxy (ex: a load balancer), the address is taken from X-Forwarded-For instead:
+// the last address that isn't one of the trusted proxies, since anything before it could
+// have been sent by the client.
+func ClientIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := clientIP(r, trustedProxies)
+			next.ServeHTTP(w, r.WithContext(myCtx.SetClientIP(r.Context(), ip)))
+		})
+	}
+}
+
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if !trusted(host, trustedProxies) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr := strings.TrimSpace(forwarded[i])
+		if len(addr) == 0 {
+			continue
+		}
+		host = addr
+		if !trusted(addr, trustedProxies) {
+			break
+		}
+	}
+	return host
+}
+
+func trusted(ip string, trustedProxies []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
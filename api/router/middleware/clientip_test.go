@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trustedProxies, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  string
+		want       string
+	}{
+		{"direct", "203.0.113.7:1234", "", "203.0.113.7"},
+		{"untrusted proxy is ignored", "203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"trusted proxy", "10.1.2.3:1234", "198.51.100.1", "198.51.100.1"},
+		{"spoofed addresses before the proxies are skipped", "10.1.2.3:1234", "1.1.1.1, 198.51.100.1, 192.168.1.1", "198.51.100.1"},
+		{"only proxies", "10.1.2.3:1234", "10.0.0.1", "10.0.0.1"},
+		{"trusted proxy without the header", "192.168.1.1:1234", "", "192.168.1.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			if len(tt.forwarded) > 0 {
+				r.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := clientIP(r, trustedProxies); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxiesInvalid(t *testing.T) {
+	if _, err := ParseTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Error("ParseTrustedProxies() should fail for an invalid address")
+	}
+}
@@ -0,0 +1,17 @@
+package middleware
+
+import "net/http"
+
+// Limit request bodies to limit bytes (no limit if zero). Reading past the limit fails with
+// an *http.MaxBytesError, which handlers turn into a 413 (see util.StatusBodyTooLarge).
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		body    string
+		tooLong bool
+	}{
+		{"within the limit", 10, "0123456789", false},
+		{"over the limit", 10, "0123456789a", true},
+		{"no limit", 0, strings.Repeat("a", 1000), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var readErr error
+			handler := MaxBodySize(tt.limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, readErr = io.ReadAll(r.Body)
+			}))
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body)))
+
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(readErr, &maxBytesErr) != tt.tooLong {
+				t.Errorf("reading the body failed with %v, should be too long: %t", readErr, tt.tooLong)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// HTTP middleware wrapped around the router.
+package middleware
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Wrap h in each middleware, with the first one being the outermost (run first).
+func Chain(h http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// Apply mw to every request except those for the given paths (ex: health checks, which
+// shouldn't be blocked because of other requests from the same IP).
+func Except(mw func(http.Handler) http.Handler, paths ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if slices.Contains(paths, r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			wrapped.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Keeps the status code and size of the response written by a handler.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	n, err := rr.ResponseWriter.Write(b)
+	rr.bytes += n
+	return n, err
+}
+
+// Allow http.ResponseController to reach the underlying ResponseWriter.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/internal/apikey"
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Kinds of client tracked by the Monitor, used in logs and metric labels.
+const (
+	kindIP     = "ip"
+	kindAPIKey = "api_key"
+)
+
+var (
+	flaggedClients = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "qlik_monitor_flagged_clients_total",
+		Help: "Clients that went over the 4xx threshold, by kind (ip or api_key).",
+	}, []string{"kind"})
+	blockedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "qlik_monitor_blocked_requests_total",
+		Help: "Requests refused because the client was blocked, by kind (ip or api_key).",
+	}, []string{"kind"})
+)
+
+// Suspicious traffic monitor settings.
+type MonitorConfig struct {
+	Window        time.Duration // Sliding window 4xx responses are counted over
+	IPThreshold   int           // 4xx responses per window before a client IP is flagged, 0 to not track IPs
+	KeyThreshold  int           // 4xx responses per window before an API key is flagged, 0 to not track API keys
+	Block         bool          // Refuse requests from flagged clients
+	BlockDuration time.Duration // How long flagged clients are blocked for
+}
+
+// Counts the 4xx responses sent to each client IP and API key (ex: spammers probing for
+// endpoints or guessing API keys). Clients that go over a threshold are logged and, if
+// blocking is turned on, refused with a 403 Forbidden for a while.
+//
+// API keys are tracked whether they are sent in the request headers or in the api_key field
+// of a JSON body (as Message writes do). Only the start of the body is read to find it (see
+// user.APIKeyFromRequestOrBody), and it's put back for the handler, so this stays cheap even
+// though the Monitor runs before the rate limiter. API keys aren't looked up, only hashed.
+type Monitor struct {
+	config MonitorConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	clients   map[trackedClient]*clientHistory
+	lastSweep time.Time
+}
+
+type trackedClient struct {
+	kind string // kindIP or kindAPIKey
+	id   string // IP address, or a fingerprint of the API key (never the key itself)
+}
+
+type clientHistory struct {
+	hits         []time.Time // 4xx responses within the window, oldest first
+	flagged      bool        // Already logged for going over the threshold
+	blockedUntil time.Time
+}
+
+// Create a new Monitor with the given settings.
+func NewMonitor(c MonitorConfig) *Monitor {
+	return &Monitor{
+		config:  c,
+		now:     time.Now,
+		clients: make(map[trackedClient]*clientHistory),
+	}
+}
+
+// Refuse requests from blocked clients, and count the 4xx responses sent to the others.
+func (m *Monitor) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clients := m.identify(r)
+		if client, until, ok := m.blocked(clients); ok {
+			blockedRequests.WithLabelValues(client.kind).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(int(until.Sub(m.now()).Seconds())+1))
+			util.Status403Forbidden(w, r, errors.New("request refused, client is blocked for sending too many bad requests"))
+			return
+		}
+
+		recorder := newResponseRecorder(w)
+		next.ServeHTTP(recorder, r)
+		if recorder.status >= 400 && recorder.status < 500 {
+			m.record(r.Context(), clients)
+		}
+	})
+}
+
+// Get the clients to track for the request.
+func (m *Monitor) identify(r *http.Request) []trackedClient {
+	var clients []trackedClient
+	if m.config.IPThreshold > 0 {
+		ip := myCtx.GetClientIP(r.Context())
+		if len(ip) == 0 {
+			ip = clientIP(r, nil)
+		}
+		clients = append(clients, trackedClient{kindIP, ip})
+	}
+	if m.config.KeyThreshold <= 0 {
+		return clients
+	}
+	if key := user.APIKeyFromRequestOrBody(r); len(key) > 0 {
+		clients = append(clients, trackedClient{kindAPIKey, apikey.HashByteToString(apikey.HashAPIKey(key))[:16]})
+	}
+	return clients
+}
+
+// Check whether any of the clients is blocked, returning the first one and until when.
+func (m *Monitor) blocked(clients []trackedClient) (trackedClient, time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	for _, client := range clients {
+		if history, ok := m.clients[client]; ok && history.blockedUntil.After(now) {
+			return client, history.blockedUntil, true
+		}
+	}
+	return trackedClient{}, time.Time{}, false
+}
+
+// Count a 4xx response for each client, flagging (and blocking) those over their threshold.
+func (m *Monitor) record(ctx context.Context, clients []trackedClient) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+
+	for _, client := range clients {
+		threshold := m.config.IPThreshold
+		if client.kind == kindAPIKey {
+			threshold = m.config.KeyThreshold
+		}
+
+		history, ok := m.clients[client]
+		if !ok {
+			history = &clientHistory{}
+			m.clients[client] = history
+		}
+		history.prune(now.Add(-m.config.Window))
+		history.hits = append(history.hits, now)
+		// Only the hits needed to tell whether the threshold was crossed are kept.
+		if len(history.hits) > threshold+1 {
+			history.hits = history.hits[len(history.hits)-threshold-1:]
+		}
+		if len(history.hits) <= threshold {
+			history.flagged = false
+			continue
+		}
+		if history.flagged {
+			continue
+		}
+
+		history.flagged = true
+		flaggedClients.WithLabelValues(client.kind).Inc()
+		logger := myCtx.GetLogger(ctx).With("kind", client.kind, "client", client.id, "threshold", threshold, "window", m.config.Window)
+		if m.config.Block {
+			history.blockedUntil = now.Add(m.config.BlockDuration)
+			history.hits = nil
+			history.flagged = false
+			logger.Warn("blocking suspicious client", "until", history.blockedUntil)
+		} else {
+			logger.Warn("suspicious client")
+		}
+	}
+}
+
+// Drop clients that have nothing left to track, at most once per window.
+func (m *Monitor) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < m.config.Window {
+		return
+	}
+	m.lastSweep = now
+	for client, history := range m.clients {
+		history.prune(now.Add(-m.config.Window))
+		if len(history.hits) == 0 && !history.blockedUntil.After(now) {
+			delete(m.clients, client)
+		}
+	}
+}
+
+// Remove hits from before since.
+func (h *clientHistory) prune(since time.Time) {
+	i := 0
+	for i < len(h.hits) && !h.hits[i].After(since) {
+		i++
+	}
+	h.hits = h.hits[i:]
+}
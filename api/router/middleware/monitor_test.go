@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Serve a request from ip, responding with status.
+func serveMonitored(handler http.Handler, ip string, status int) int {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = ip + ":1234"
+	r.Header.Set("X-Status", http.StatusText(status))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w.Code
+}
+
+func newMonitoredHandler(m *Monitor) http.Handler {
+	return m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Status") == http.StatusText(http.StatusNotFound) {
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestMonitorBlocksAfterThreshold(t *testing.T) {
+	now := time.Now()
+	m := NewMonitor(MonitorConfig{Window: time.Minute, IPThreshold: 3, Block: true, BlockDuration: 10 * time.Minute})
+	m.now = func() time.Time { return now }
+	handler := newMonitoredHandler(m)
+
+	for i := 0; i < 3; i++ {
+		serveMonitored(handler, "203.0.113.7", http.StatusNotFound)
+	}
+	if got := serveMonitored(handler, "203.0.113.7", http.StatusOK); got != http.StatusOK {
+		t.Fatalf("status = %d, should not block at the threshold", got)
+	}
+
+	serveMonitored(handler, "203.0.113.7", http.StatusNotFound)
+	if got := serveMonitored(handler, "203.0.113.7", http.StatusOK); got != http.StatusForbidden {
+		t.Errorf("status = %d, should block once over the threshold", got)
+	}
+	if got := serveMonitored(handler, "198.51.100.1", http.StatusOK); got != http.StatusOK {
+		t.Errorf("status = %d, other clients should not be blocked", got)
+	}
+
+	now = now.Add(11 * time.Minute)
+	if got := serveMonitored(handler, "203.0.113.7", http.StatusOK); got != http.StatusOK {
+		t.Errorf("status = %d, should unblock after the block duration", got)
+	}
+}
+
+func TestMonitorBodyAPIKey(t *testing.T) {
+	m := NewMonitor(MonitorConfig{Window: time.Minute, KeyThreshold: 2, Block: true, BlockDuration: time.Minute})
+	handler := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+	}))
+
+	// Bad Message writes keyed in the body are tracked by API key, whatever IP they come from.
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 4; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/messages", strings.NewReader(`{"api_key": "b16fc69c", "message": 1}`))
+		r.RemoteAddr = "203.0.113." + strconv.Itoa(i) + ":1234"
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+	}
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, the API key should be blocked once over the threshold", w.Code)
+	}
+}
+
+func TestMonitorSlidingWindow(t *testing.T) {
+	now := time.Now()
+	m := NewMonitor(MonitorConfig{Window: time.Minute, IPThreshold: 2, Block: true, BlockDuration: time.Minute})
+	m.now = func() time.Time { return now }
+	handler := newMonitoredHandler(m)
+
+	// Errors spread out over more than the window never go over the threshold.
+	for i := 0; i < 6; i++ {
+		serveMonitored(handler, "203.0.113.7", http.StatusNotFound)
+		now = now.Add(31 * time.Second)
+	}
+	if got := serveMonitored(handler, "203.0.113.7", http.StatusOK); got != http.StatusOK {
+		t.Errorf("status = %d, errors outside the window should not count", got)
+	}
+}
+
+func TestExcept(t *testing.T) {
+	m := NewMonitor(MonitorConfig{Window: time.Minute, IPThreshold: 1, Block: true, BlockDuration: time.Minute})
+	handler := Except(m.Handler, "/healthz")(http.HandlerFunc(http.NotFound))
+
+	for _, path := range []string{"/a", "/b"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	for path, want := range map[string]int{"/a": http.StatusForbidden, "/healthz": http.StatusNotFound} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != want {
+			t.Errorf("%s status = %d, want %d", path, w.Code, want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/ratelimit"
+	"github.com/agnate/qlikrestapi/internal/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var rateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "qlik_rate_limited_requests_total",
+	Help: "Requests refused with a 429 Too Many Requests, by rate limit group.",
+}, []string{"group"})
+
+// Routes sharing a rate limit.
+type RateLimitGroup struct {
+	Name    string   // Used in bucket keys and metrics (ex: "write")
+	Methods []string // Request methods the group applies to, or empty for any
+	Path    string   // Exact path, or a prefix when it ends with a / (ex: /api/)
+	Rate    ratelimit.Rate
+}
+
+func (g *RateLimitGroup) matches(r *http.Request) bool {
+	if len(g.Methods) > 0 && !slices.Contains(g.Methods, r.Method) {
+		return false
+	}
+	if strings.HasSuffix(g.Path, "/") {
+		return strings.HasPrefix(r.URL.Path, g.Path)
+	}
+	return r.URL.Path == g.Path
+}
+
+// Returned by the identify function given to NewRateLimiter when the request has an API key
+// that doesn't belong to any User.
+var ErrUnknownAPIKey = errors.New("unknown api key")
+
+// Limits how often each client can make requests, with a token bucket per rate limit group.
+// Authenticated Users are limited by account, and everyone else by IP address.
+type RateLimiter struct {
+	store       ratelimit.Store
+	identify    func(r *http.Request) (string, error)
+	groups      []RateLimitGroup
+	unknownKeys ratelimit.Rate // Unknown API keys looked up per IP, no limit if zero
+
+	mu          sync.Mutex
+	now         func() time.Time
+	skipLookups map[string]time.Time // IPs that sent too many unknown API keys, until when
+}
+
+// Create a new RateLimiter keeping its buckets in store. identify returns the ID of the
+// User whose API key was sent with the request, an empty string if there isn't one, or
+// ErrUnknownAPIKey if it doesn't belong to any User (so clients can't get a fresh bucket by
+// making up API keys). A request uses the first group it matches, and isn't limited if it
+// matches none.
+func NewRateLimiter(store ratelimit.Store, identify func(r *http.Request) (string, error), groups ...RateLimitGroup) *RateLimiter {
+	return &RateLimiter{
+		store:       store,
+		identify:    identify,
+		groups:      groups,
+		now:         time.Now,
+		skipLookups: make(map[string]time.Time),
+	}
+}
+
+// Limit how many unknown API keys each IP can send. Unknown keys aren't cached, so each one
+// costs a database lookup. Once an IP goes over rate, its requests are limited by IP without
+// looking up their API key, until its bucket has a token again.
+func (rl *RateLimiter) LimitUnknownKeys(rate ratelimit.Rate) *RateLimiter {
+	rl.unknownKeys = rate
+	return rl
+}
+
+// Take a token for the request, refusing it with a 429 Too Many Requests if there are none
+// left. The RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers tell clients
+// where they stand, and Retry-After when to try again.
+func (rl *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		group := rl.match(r)
+		if group == nil || !group.Rate.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		result, err := rl.store.Take(r.Context(), group.Name+":"+rl.client(r), group.Rate)
+		if err != nil {
+			// Let requests through rather than taking the API down with the store.
+			myCtx.GetLogger(r.Context()).Warn("unable to check rate limit, allowing request", "group", group.Name, "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+		w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+		if !result.Allowed {
+			rateLimited.WithLabelValues(group.Name).Inc()
+			w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+			util.Status429TooManyRequests(w, r, errors.New("rate limit exceeded for "+group.Name+" requests"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (rl *RateLimiter) match(r *http.Request) *RateLimitGroup {
+	for i := range rl.groups {
+		if rl.groups[i].matches(r) {
+			return &rl.groups[i]
+		}
+	}
+	return nil
+}
+
+// Get the key of the bucket for the client that made the request.
+func (rl *RateLimiter) client(r *http.Request) string {
+	ip := myCtx.GetClientIP(r.Context())
+	if len(ip) == 0 {
+		ip = clientIP(r, nil)
+	}
+	if rl.identify == nil || rl.skipLookup(ip) {
+		return "ip:" + ip
+	}
+
+	id, err := rl.identify(r)
+	if errors.Is(err, ErrUnknownAPIKey) {
+		rl.countUnknownKey(r.Context(), ip)
+	}
+	if len(id) > 0 {
+		return "user:" + id
+	}
+	return "ip:" + ip
+}
+
+// Check whether the IP has sent too many unknown API keys to look up any more for now.
+func (rl *RateLimiter) skipLookup(ip string) bool {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	until, ok := rl.skipLookups[ip]
+	return ok && until.After(rl.now())
+}
+
+// Take a token from the IP's unknown API key bucket, skipping lookups for its requests until
+// there is one again if it's empty.
+func (rl *RateLimiter) countUnknownKey(ctx context.Context, ip string) {
+	if !rl.unknownKeys.Enabled() {
+		return
+	}
+	result, err := rl.store.Take(ctx, "unknown_key:ip:"+ip, rl.unknownKeys)
+	if err != nil || result.Allowed {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := rl.now()
+	// Drop IPs that can be looked up again, so the map only holds current offenders.
+	for other, until := range rl.skipLookups {
+		if !until.After(now) {
+			delete(rl.skipLookups, other)
+		}
+	}
+	rl.skipLookups[ip] = now.Add(result.RetryAfter)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/internal/ratelimit"
+	"github.com/agnate/qlikrestapi/internal/util"
+)
+
+// Identify the User sending the API key "valid", counting the lookups in lookups.
+func identifyTestUser(lookups *int) func(r *http.Request) (string, error) {
+	return func(r *http.Request) (string, error) {
+		switch key := user.APIKeyFromRequestOrBody(r); key {
+		case "":
+			return "", nil
+		case "valid":
+			*lookups++
+			return "user-1", nil
+		default:
+			*lookups++
+			return "", ErrUnknownAPIKey
+		}
+	}
+}
+
+func newRateLimitedHandler(store ratelimit.Store) http.Handler {
+	rl := NewRateLimiter(store, identifyTestUser(new(int)),
+		RateLimitGroup{Name: "write", Methods: []string{http.MethodPost}, Path: "/api/", Rate: ratelimit.Rate{Limit: 2, Period: time.Minute}},
+		RateLimitGroup{Name: "read", Path: "/api/", Rate: ratelimit.Rate{Limit: 100, Period: time.Minute}},
+	)
+	return rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+}
+
+func serveRateLimited(handler http.Handler, method string, path string, ip string, apiKey string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	r.RemoteAddr = ip + ":1234"
+	if len(apiKey) > 0 {
+		r.Header.Set("X-API-Key", apiKey)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimiter(t *testing.T) {
+	handler := newRateLimitedHandler(ratelimit.NewMemory())
+
+	for i := 1; i >= 0; i-- {
+		w := serveRateLimited(handler, http.MethodPost, "/api/v1/messages", "203.0.113.7", "")
+		if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "2" || w.Header().Get("RateLimit-Remaining") != strconv.Itoa(i) {
+			t.Fatalf("status = %d with headers %v, want 200 with %d remaining", w.Code, w.Header(), i)
+		}
+	}
+	w := serveRateLimited(handler, http.MethodPost, "/api/v1/messages", "203.0.113.7", "")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "30" || w.Header().Get("RateLimit-Reset") != "60" {
+		t.Errorf("status = %d with headers %v, want 429 with Retry-After 30 and reset 60", w.Code, w.Header())
+	}
+
+	// Groups and clients have their own buckets.
+	if w := serveRateLimited(handler, http.MethodGet, "/api/v1/messages", "203.0.113.7", ""); w.Code != http.StatusOK {
+		t.Errorf("read status = %d, should use the read group", w.Code)
+	}
+	if w := serveRateLimited(handler, http.MethodPost, "/api/v1/messages", "198.51.100.1", ""); w.Code != http.StatusOK {
+		t.Errorf("status = %d, other IPs should have their own bucket", w.Code)
+	}
+	if w := serveRateLimited(handler, http.MethodPost, "/api/v1/messages", "203.0.113.7", "valid"); w.Code != http.StatusOK {
+		t.Errorf("status = %d, authenticated Users should be limited by account", w.Code)
+	}
+	if w := serveRateLimited(handler, http.MethodPost, "/api/v1/messages", "203.0.113.7", "made-up"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, invalid API keys should be limited by IP", w.Code)
+	}
+
+	// Requests outside the groups aren't limited.
+	if w := serveRateLimited(handler, http.MethodPost, "/healthz", "203.0.113.7", ""); w.Code != http.StatusOK || len(w.Header().Get("RateLimit-Limit")) > 0 {
+		t.Errorf("status = %d with headers %v, should not be limited", w.Code, w.Header())
+	}
+}
+
+func TestRateLimiterBodyAPIKey(t *testing.T) {
+	rl := NewRateLimiter(ratelimit.NewMemory(), identifyTestUser(new(int)), RateLimitGroup{Name: "write", Methods: []string{http.MethodPost}, Path: "/api/", Rate: ratelimit.Rate{Limit: 2, Period: time.Minute}})
+	handler := rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The handler can still read the body the API key was taken from.
+		if _, err := util.DecodeJSONBody[struct {
+			APIKey  string `json:"api_key"`
+			Message string `json:"message"`
+		}](r); err != nil {
+			t.Errorf("an error '%s' was not expected while decoding the body", err)
+		}
+	}))
+
+	// A User sending their API key in the body is limited by account, whatever IP they use.
+	var w *httptest.ResponseRecorder
+	for i := 0; i < 3; i++ {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/messages", strings.NewReader(`{"api_key": "valid", "message": "kayak"}`))
+		r.RemoteAddr = "203.0.113." + strconv.Itoa(i) + ":1234"
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 once the account's bucket is empty", w.Code)
+	}
+}
+
+func TestRateLimiterUnknownKeys(t *testing.T) {
+	now := time.Now()
+	var lookups int
+	rl := NewRateLimiter(ratelimit.NewMemory(), identifyTestUser(&lookups),
+		RateLimitGroup{Name: "read", Path: "/api/", Rate: ratelimit.Rate{Limit: 100, Period: time.Minute}},
+	).LimitUnknownKeys(ratelimit.Rate{Limit: 2, Period: time.Minute})
+	rl.now = func() time.Time { return now }
+	handler := rl.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	// Once an IP has used up its unknown API keys, its API keys stop being looked up.
+	for i := 0; i < 5; i++ {
+		serveRateLimited(handler, http.MethodGet, "/api/v1/messages", "203.0.113.7", "made-up-"+strconv.Itoa(i))
+	}
+	if lookups != 3 {
+		t.Errorf("looked up %d API keys, want 3", lookups)
+	}
+	serveRateLimited(handler, http.MethodGet, "/api/v1/messages", "198.51.100.1", "valid")
+	if lookups != 4 {
+		t.Errorf("looked up %d API keys, other IPs should still be looked up", lookups)
+	}
+
+	// Lookups start again once the bucket has a token.
+	now = now.Add(31 * time.Second)
+	serveRateLimited(handler, http.MethodGet, "/api/v1/messages", "203.0.113.7", "valid")
+	if lookups != 5 {
+		t.Errorf("looked up %d API keys, should look up again after the retry time", lookups)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, rate ratelimit.Rate) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimiterStoreDown(t *testing.T) {
+	handler := newRateLimitedHandler(failingStore{})
+	if w := serveRateLimited(handler, http.MethodPost, "/api/v1/messages", "203.0.113.7", ""); w.Code != http.StatusOK {
+		t.Errorf("status = %d, requests should be allowed when the store is down", w.Code)
+	}
+}
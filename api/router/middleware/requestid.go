@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/google/uuid"
+)
+
+// Longest X-Request-ID accepted from clients, anything longer is replaced.
+const maxRequestIDLength = 128
+
+// Give every request an ID, taken from the X-Request-ID header if the client (or a proxy in
+// front of the API) sent a valid one, or generated otherwise. The ID is echoed in the
+// response headers and added to the request context along with a logger that includes it,
+// so everything logged for the request can be found again from the ID.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(myCtx.RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(myCtx.RequestIDHeader, id)
+
+		ctx := myCtx.SetRequestID(r.Context(), id)
+		ctx = myCtx.SetLogger(ctx, slog.Default().With("request_id", id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Only accept IDs that are safe to echo in headers and write to logs.
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{"generated when missing", "", false},
+		{"propagated", "abc-123", true},
+		{"replaced when too long", strings.Repeat("a", maxRequestIDLength+1), false},
+		{"replaced when it has spaces", "abc 123", false},
+		{"replaced when it has control characters", "abc\x01", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ctxID string
+			handler := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				ctxID = myCtx.GetRequestID(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if len(tt.incoming) > 0 {
+				r.Header.Set(myCtx.RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			got := w.Header().Get(myCtx.RequestIDHeader)
+			if len(got) == 0 {
+				t.Fatal("response should have a request ID")
+			}
+			if got != ctxID {
+				t.Errorf("context request ID = %q, want %q", ctxID, got)
+			}
+			if (got == tt.incoming) != tt.keep {
+				t.Errorf("request ID = %q, incoming %q kept should be %t", got, tt.incoming, tt.keep)
+			}
+		})
+	}
+}
@@ -2,16 +2,20 @@
 package router
 
 import (
-	"database/sql"
 	"errors"
 	"net/http"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/agnate/qlikrestapi/api/entity/message"
+	"github.com/agnate/qlikrestapi/api/entity/privacy"
 	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/api/health"
+	"github.com/agnate/qlikrestapi/api/store"
 	myCtx "github.com/agnate/qlikrestapi/internal/context"
 	"github.com/agnate/qlikrestapi/internal/util"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 // Contains the routers for API to serve.
@@ -22,14 +26,18 @@ type Router struct {
 // Contains a route for use in Router.
 type route struct {
 	method  string
+	pattern string // As given to newRoute, used to label metrics
 	regex   *regexp.Regexp
 	handler http.HandlerFunc
 }
 
-// Build a new Router containing all of the API routes and handlers.
-func New(db *sql.DB) *Router {
-	msgAPI := message.New(db)
-	userAPI := user.New(db)
+// Build a new Router containing all of the API routes and handlers, backed by the given storage.
+// Email verification is disabled when verifier is nil, and personal data requests can
+// only be made by the User themselves when complianceKey is empty.
+func New(store *store.Store, verifier *user.Verifier, complianceKey string, healthAPI *health.API) *Router {
+	userAPI := user.New(store.Users, verifier)
+	msgAPI := message.New(store.Messages, userAPI)
+	privacyAPI := privacy.New(store, userAPI, complianceKey)
 
 	return &Router{
 		routes: []route{
@@ -41,6 +49,16 @@ func New(db *sql.DB) *Router {
 			newRoute(http.MethodDelete, "/api/v1/messages/([^/]+)/([^/]+)", msgAPI.Delete), // [DELETE] UUID, CreateDate --> Body contains: api_key, last_updated_date
 			newRoute(http.MethodGet, "/api/v1/users", userAPI.List),                        // [LIST]
 			newRoute(http.MethodPost, "/api/v1/users", userAPI.Create),                     // [CREATE] --> Body contains: full_name, email
+			newRoute(http.MethodGet, "/api/v1/users/verify", userAPI.Verify),               // [VERIFY] --> URL contains: token
+			newRoute(http.MethodGet, "/api/v1/users/([^/]+)/export", privacyAPI.Export),    // [EXPORT] UUID --> Header contains: X-API-Key
+			newRoute(http.MethodPost, "/api/v1/users/([^/]+)/erase", privacyAPI.Erase),     // [ERASE] UUID --> Header contains: X-API-Key
+			newRoute(http.MethodGet, "/api/v1/me", userAPI.ReadMe),                         // [READ] --> Header contains: X-API-Key
+			newRoute(http.MethodPatch, "/api/v1/me", userAPI.UpdateMe),                     // [UPDATE] --> Header contains: X-API-Key, Body contains: full_name, email
+			newRoute(http.MethodGet, "/api/v1/me/messages", msgAPI.ListMine),               // [LIST] --> Header contains: X-API-Key
+			newRoute(http.MethodPost, "/api/v1/me/rotate-key", userAPI.RotateKey),          // [ROTATE] --> Header contains: X-API-Key
+			newRoute(http.MethodGet, "/metrics", promhttp.Handler().ServeHTTP),             // [METRICS] Prometheus
+			newRoute(http.MethodGet, "/healthz", healthAPI.Live),                           // [HEALTH] Process is alive
+			newRoute(http.MethodGet, "/readyz", healthAPI.Ready),                           // [HEALTH] Database, migrations and shutdown status
 		},
 	}
 }
@@ -52,7 +70,7 @@ func New(db *sql.DB) *Router {
 //   - pattern: Supports REGEX, with pattern being sandwiched between start/end metachars as follows: ^pattern$
 //   - handler: Function to invoke when router is matched
 func newRoute(method, pattern string, handler http.HandlerFunc) route {
-	return route{method, regexp.MustCompile("^" + pattern + "$"), handler}
+	return route{method, pattern, regexp.MustCompile("^" + pattern + "$"), handler}
 }
 
 // Create new http.Handler for this Router for use by [net/http.ListenAndServe].
@@ -62,6 +80,18 @@ func (rt *Router) NewHandler() http.Handler {
 
 // Uses the [net/http.Request] to match a valid, allowed route and invoke its handler.
 func (rt *Router) serve(w http.ResponseWriter, r *http.Request) {
+	// Record the status code, so the request can be counted and traced once it's done.
+	start := time.Now()
+	r, span := startSpan(r)
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	pattern := rt.match(recorder, r)
+	endSpan(span, pattern, r, recorder.status)
+	observeRequest(pattern, r, recorder.status, start)
+}
+
+// Invoke the handler of the route matching the request. Returns the pattern of the
+// matched route, or an empty string if there wasn't one.
+func (rt *Router) match(w http.ResponseWriter, r *http.Request) string {
 	var allow []string
 	for _, route := range rt.routes {
 		// Use regex to match the route and store the match in the Context.
@@ -77,15 +107,16 @@ func (rt *Router) serve(w http.ResponseWriter, r *http.Request) {
 			// Add the regex match to the Context and invoke the route's handler.
 			ctx := myCtx.SetContextRouteData(r.Context(), matches[1:])
 			route.handler(w, r.WithContext(ctx))
-			return
+			return route.pattern
 		}
 	}
 	// We found a matching route, but the methods didn't match (GET/POST), so
 	// inform user that method isn't allowed.
 	if len(allow) > 0 {
 		w.Header().Set("Allow", strings.Join(allow, ", "))
-		util.Status405APINotAllowed(w, errors.New("invalid route method supplied"))
-		return
+		util.Status405APINotAllowed(w, r, errors.New("invalid route method supplied"))
+		return ""
 	}
 	http.NotFound(w, r)
+	return ""
 }
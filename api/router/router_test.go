@@ -0,0 +1,81 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/health"
+	"github.com/agnate/qlikrestapi/api/store"
+	"github.com/agnate/qlikrestapi/internal/tracing"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestMetricsUseRoutePattern(t *testing.T) {
+	handler := New(store.NewMemory(), nil, "", health.New(time.Second)).NewHandler()
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/messages/"+uuid.NewString(), nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/not/a/route", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/api/v1/messages", nil))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	for _, want := range []string{
+		`qlik_http_requests_total{method="GET",route="/api/v1/messages/([^/]+)",status="200"} 3`,
+		`qlik_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+		`qlik_http_requests_total{method="other",route="unmatched",status="405"} 1`,
+		`qlik_http_request_duration_seconds_count{method="GET",route="/api/v1/messages/([^/]+)",status="200"} 3`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics should contain %s", want)
+		}
+	}
+}
+
+func TestTracingUsesRoutePatternAndTraceparent(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	if _, err := tracing.Setup(tracing.Config{Exporter: "none"}); err != nil {
+		t.Fatal(err)
+	}
+	handler := New(store.NewMemory(), nil, "", health.New(time.Second)).NewHandler()
+
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/messages/"+uuid.NewString(), nil)
+	r.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	if got, want := spans[0].Name(), "GET /api/v1/messages/([^/]+)"; got != want {
+		t.Errorf("span name = %q, want %q", got, want)
+	}
+	if got := spans[0].SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("trace ID = %s, should continue the caller's trace", got)
+	}
+	if got := spans[0].Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span ID = %s, should be the caller's span", got)
+	}
+}
+
+func TestDebugVarsNotServed(t *testing.T) {
+	handler := New(store.NewMemory(), nil, "", health.New(time.Second)).NewHandler()
+
+	// expvar includes the command line, which can hold secrets, so it must not be public.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("/debug/vars returned status %d, should be %d", w.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,38 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/agnate/qlikrestapi/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Start the span for a request, joining the caller's trace if they sent a traceparent
+// header. It's named after the method until the route is known.
+func startSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := tracing.Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := tracing.Start(ctx, r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		))
+	return r.WithContext(ctx), span
+}
+
+// Name the span after the route pattern (like the metrics, so UUIDs in the path don't
+// make every span name unique) and end it.
+func endSpan(span trace.Span, pattern string, r *http.Request, status int) {
+	if len(pattern) > 0 {
+		span.SetName(r.Method + " " + pattern)
+		span.SetAttributes(attribute.String("http.route", pattern))
+	}
+	span.SetAttributes(attribute.Int("http.response.status_code", status))
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+	span.End()
+}
@@ -0,0 +1,64 @@
+package store
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/message"
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/internal/cache"
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+)
+
+// Get a copy of the Store that caches lookups in c for ttl.
+//
+// Inside WithTx nothing is added to the cache (so uncommitted data is never cached), and
+// anything changed by the transaction is removed from the cache again once it is committed,
+// in case another request cached the old data in the meantime.
+func (s *Store) WithCache(c cache.Cache, ttl time.Duration) *Store {
+	cached := &Store{
+		Messages: message.NewCachedMessageStorage(s.Messages, c, ttl),
+		Users:    user.NewCachedUserStorage(s.Users, c, ttl),
+	}
+	cached.withTx = func(ctx context.Context, fn func(tx *Store) error) error {
+		txc := &txCache{cache: c}
+		err := s.withTx(ctx, func(tx *Store) error {
+			return fn(newTxStore(
+				message.NewCachedMessageStorage(tx.Messages, txc, ttl),
+				user.NewCachedUserStorage(tx.Users, txc, ttl),
+			))
+		})
+		if err == nil && len(txc.deleted) > 0 {
+			if err := c.Delete(ctx, txc.deleted...); err != nil {
+				myCtx.GetLogger(ctx).Warn("cache error", "error", err)
+			}
+		}
+		return err
+	}
+	return cached
+}
+
+// Cache used inside a transaction. Nothing is written to the cache, and keys changed by the
+// transaction always miss so its own changes are read back from the database. Deletes are
+// passed on straight away and remembered so they can be repeated after commit.
+type txCache struct {
+	cache   cache.Cache
+	deleted []string
+}
+
+func (c *txCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	if slices.Contains(c.deleted, key) {
+		return nil, false, nil
+	}
+	return c.cache.Get(ctx, key)
+}
+
+func (c *txCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return nil
+}
+
+func (c *txCache) Delete(ctx context.Context, keys ...string) error {
+	c.deleted = append(c.deleted, keys...)
+	return c.cache.Delete(ctx, keys...)
+}
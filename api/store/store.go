@@ -0,0 +1,73 @@
+// Groups the entity repositories together so they can be shared by the API handlers, and
+// lets operations that touch several entities run inside a single transaction.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/message"
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/internal/database"
+)
+
+type Store struct {
+	Messages message.MessageRepository
+	Users    user.UserRepository
+	withTx   func(ctx context.Context, fn func(tx *Store) error) error
+}
+
+// Create a Store backed by a SQL database. List and read queries are sent to the replicas
+// when there are any, and queries taking longer than queryTimeout are aborted (zero means
+// no timeout). Transactions always run on db.
+func NewSQL(db *sql.DB, replicas []*sql.DB, queryTimeout time.Duration) *Store {
+	messages := message.NewMessageStorage(db).WithReplicas(replicas...).WithQueryTimeout(queryTimeout)
+	users := user.NewUserStorage(db).WithReplicas(replicas...).WithQueryTimeout(queryTimeout)
+
+	return &Store{
+		Messages: messages,
+		Users:    users,
+		withTx: func(ctx context.Context, fn func(tx *Store) error) error {
+			return database.WithTx(ctx, db, func(tx *sql.Tx) error {
+				return fn(newTxStore(messages.WithTx(tx), users.WithTx(tx)))
+			})
+		},
+	}
+}
+
+// Create a Store backed by in-memory storage. Transactions are run one at a time, but
+// changes are not rolled back if they fail.
+func NewMemory() *Store {
+	var mu sync.Mutex
+	s := &Store{
+		Messages: message.NewMemoryMessageStorage(),
+		Users:    user.NewMemoryUserStorage(),
+	}
+	s.withTx = func(ctx context.Context, fn func(tx *Store) error) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return fn(newTxStore(s.Messages, s.Users))
+	}
+	return s
+}
+
+// Run fn with repositories that share a single transaction. The transaction is committed
+// if fn returns nil, otherwise it is rolled back.
+func (s *Store) WithTx(ctx context.Context, fn func(tx *Store) error) error {
+	return s.withTx(ctx, fn)
+}
+
+// Create a Store for repositories that are already inside a transaction, where any
+// nested WithTx simply joins the existing transaction.
+func newTxStore(messages message.MessageRepository, users user.UserRepository) *Store {
+	tx := &Store{
+		Messages: messages,
+		Users:    users,
+	}
+	tx.withTx = func(ctx context.Context, fn func(tx *Store) error) error {
+		return fn(tx)
+	}
+	return tx
+}
@@ -0,0 +1,98 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/message"
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/internal/cache"
+	"github.com/agnate/qlikrestapi/internal/database/dbtest"
+)
+
+func TestSQLWithTxRollsBack(t *testing.T) {
+	s := NewSQL(dbtest.NewSQLite(t), nil, 0)
+
+	author, err := s.Users.Create(context.Background(), &user.User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	msg, err := s.Messages.Create(context.Background(), &message.Message{UUID: author.UUID, Message: "radar", Palindrome: true, LastUpdatedBy: author.UUID})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a message", err)
+	}
+
+	// Fail after both repositories have written, so everything should be rolled back.
+	failure := errors.New("failure")
+	err = s.WithTx(context.Background(), func(tx *Store) error {
+		if err := tx.Messages.Scrub(context.Background(), author.UUID); err != nil {
+			return err
+		}
+		if _, err := tx.Users.Anonymize(context.Background(), author.UUID, "new-hash"); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("WithTx() = %v, should return the error from fn", err)
+	}
+
+	if got, _ := s.Messages.Read(context.Background(), author.UUID, msg.CreateDate); got == nil || got.Message != "radar" {
+		t.Errorf("Read() = %v, the scrub should have been rolled back", got)
+	}
+	if got, _ := s.Users.GetUserByUUID(context.Background(), author.UUID); got == nil || got.Email != "bob@example.com" {
+		t.Errorf("GetUserByUUID() = %v, the anonymize should have been rolled back", got)
+	}
+}
+
+func TestSQLWithTxCommits(t *testing.T) {
+	s := NewSQL(dbtest.NewSQLite(t), nil, 0)
+
+	author, err := s.Users.Create(context.Background(), &user.User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+
+	err = s.WithTx(context.Background(), func(tx *Store) error {
+		// Nested calls join the outer transaction.
+		return tx.WithTx(context.Background(), func(tx *Store) error {
+			_, err := tx.Users.Anonymize(context.Background(), author.UUID, "new-hash")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while running WithTx", err)
+	}
+
+	if got, _ := s.Users.GetUserByUUID(context.Background(), author.UUID); got == nil || got.Name != "" {
+		t.Errorf("GetUserByUUID() = %v, the user should have been anonymized", got)
+	}
+}
+
+func TestCachedWithTxInvalidatesAfterCommit(t *testing.T) {
+	ctx := context.Background()
+	s := NewSQL(dbtest.NewSQLite(t), nil, 0).WithCache(cache.NewLRU(100), time.Minute)
+
+	author, err := s.Users.Create(ctx, &user.User{Name: "Bob", Email: "bob@example.com", APIKey: "hash"})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while creating a user", err)
+	}
+	if found, _ := s.Users.GetUserByAPIKey(ctx, "hash"); found == nil {
+		t.Fatalf("GetUserByAPIKey() should find the created user")
+	}
+
+	err = s.WithTx(ctx, func(tx *Store) error {
+		_, err := tx.Users.Anonymize(ctx, author.UUID, "new-hash")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected while running WithTx", err)
+	}
+
+	// The cached lookup for the old API key must be gone.
+	if found, _ := s.Users.GetUserByAPIKey(ctx, "hash"); found != nil {
+		t.Errorf("GetUserByAPIKey() = %v, the old api key should not work", found)
+	}
+}
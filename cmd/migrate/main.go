@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"github.com/agnate/qlikrestapi/config"
+	"github.com/agnate/qlikrestapi/internal/database"
+	"github.com/agnate/qlikrestapi/internal/migrator"
+)
+
+const usage = `Usage: migrate [--config file] <command> [argument]
+
+Commands:
+  up [N]      Apply all pending migrations, or only the next N
+  down N      Roll back the last N migrations
+  goto V      Migrate up or down to version V
+  force V     Set the version to V without running anything and clear the dirty flag
+              (use -1 for no migrations)
+  status      Show the current version and the pending migrations
+
+The database is selected with the same environment variables (and config file) as the API.
+`
+
+func main() {
+	// Parse command line arguments.
+	configFile := flag.String("config", "", "")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() < 1 || flag.NArg() > 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command, arg := flag.Arg(0), flag.Arg(1)
+
+	// Load environment config.
+	c := config.NewFromFile(*configFile).Database
+
+	// Connect to database.
+	db, err := sql.Open(c.DriverName(), c.ConnectionString())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.Ping(context.Background(), db, c.ConnectAttempts, c.ConnectBackoff); err != nil {
+		log.Fatal(err)
+	}
+
+	// Run the command.
+	m := migrator.New(c.Migrations, c.DatabaseName, c.DriverName())
+	switch command {
+	case "up":
+		n := 0
+		if arg != "" {
+			n = parseInt(arg)
+		}
+		err = m.Up(db, n)
+	case "down":
+		err = m.Down(db, parseInt(arg))
+	case "goto":
+		version := parseInt(arg)
+		if version < 0 {
+			log.Fatalf("Invalid version `%s`\n", arg)
+		}
+		err = m.Goto(db, uint(version))
+	case "force":
+		err = m.Force(db, parseInt(arg))
+	case "status":
+		// Status is always printed below.
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	printStatus(m, db)
+}
+
+// Parse a required integer argument, exiting if it's missing or invalid.
+func parseInt(arg string) int {
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		log.Fatalf("Invalid number `%s`\n", arg)
+	}
+	return n
+}
+
+func printStatus(m *migrator.Migrator, db *sql.DB) {
+	status, err := m.Status(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("version: %d\n", status.Version)
+	fmt.Printf("dirty:   %t\n", status.Dirty)
+	fmt.Printf("latest:  %d\n", status.Latest)
+	fmt.Printf("pending: %v\n", status.Pending)
+}
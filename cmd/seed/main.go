@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+
+	"github.com/agnate/qlikrestapi/api/store"
+	"github.com/agnate/qlikrestapi/config"
+	"github.com/agnate/qlikrestapi/internal/database"
+	"github.com/agnate/qlikrestapi/internal/migrator"
+	"github.com/agnate/qlikrestapi/internal/seed"
+)
+
+func main() {
+	// Parse command line flags.
+	users := flag.Int("users", 5, "number of users to create")
+	messages := flag.Int("messages", 20, "number of messages to create, shared between the users")
+	seedValue := flag.Int64("seed", 1, "seed for generating API keys and picking messages")
+	configFile := flag.String("config", "", "YAML or TOML config file, overridden by environment variables (default $CONFIG_FILE)")
+	flag.Parse()
+
+	// Load environment config.
+	c := config.NewFromFile(*configFile).Database
+
+	// Connect to database.
+	db, err := sql.Open(c.DriverName(), c.ConnectionString())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	if err := database.Ping(context.Background(), db, c.ConnectAttempts, c.ConnectBackoff); err != nil {
+		log.Fatal(err)
+	}
+
+	// Make sure the schema is up to date, the same way the API does.
+	m := migrator.New(c.Migrations, c.DatabaseName, c.DriverName())
+	if c.AutoMigrate {
+		err = m.Run(db)
+	} else if status, statusErr := m.Status(db); statusErr != nil {
+		err = statusErr
+	} else if len(status.Pending) > 0 || status.Dirty {
+		err = fmt.Errorf("database has %d pending migrations, run them with cmd/migrate first", len(status.Pending))
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Seed the database.
+	result, err := seed.Run(context.Background(), store.NewSQL(db, nil, c.QueryTimeout), seed.Options{
+		Users:    *users,
+		Messages: *messages,
+		Seed:     *seedValue,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Print the users. API keys can only be shown when the user is created, since only
+	// the hash is stored.
+	for _, seeded := range result.Users {
+		apiKey := seeded.RawAPIKey
+		if !seeded.Created {
+			apiKey = "(already exists)"
+		}
+		fmt.Printf("%s  %-28s  %s\n", seeded.User.UUID, seeded.User.Email, apiKey)
+	}
+	fmt.Printf("created %d messages\n", result.MessagesCreated)
+}
@@ -1,63 +1,224 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Conf struct {
-	General  *ConfGeneral
-	API      *ConfAPI
-	Database *ConfDatabase
+	General      *ConfGeneral
+	Log          *ConfLog
+	Tracing      *ConfTracing
+	API          *ConfAPI
+	Monitor      *ConfMonitor
+	RateLimit    *ConfRateLimit
+	Database     *ConfDatabase
+	Mailer       *ConfMailer
+	Verification *ConfVerification
+	Cache        *ConfCache
+	Redis        *ConfRedis
 }
 
 type ConfGeneral struct {
-	Tag string
+	Tag string `env:"TAG" required:"true"`
+}
+
+type ConfLog struct {
+	Level  string `env:"LOG_LEVEL" default:"info"`  // "debug", "info", "warn" or "error"
+	Format string `env:"LOG_FORMAT" default:"text"` // "text" or "json"
+
+	// Access log, with a line for every request
+	AccessFormat string `env:"ACCESS_LOG_FORMAT" default:"combined"` // "none", "common", "combined" or "json"
+	AccessFile   string `env:"ACCESS_LOG_FILE"`                      // File the access log is appended to, or empty for stdout
+}
+
+type ConfTracing struct {
+	Exporter     string `env:"TRACING_EXPORTER" default:"none"` // "none", "stdout" or "otlp"
+	OTLPEndpoint string `env:"TRACING_OTLP_ENDPOINT"`           // OTLP/HTTP URL (ex: http://localhost:4318/v1/traces), defaults to the OTEL_EXPORTER_OTLP_* variables
+	ServiceName  string `env:"TRACING_SERVICE_NAME" default:"qlikrestapi"`
 }
 
 type ConfAPI struct {
-	Port string
+	Port           int      `env:"API_PORT" required:"true"`
+	ComplianceKey  string   `env:"COMPLIANCE_API_KEY" secret:"true"`     // API key allowed to export/erase any User's data (disabled if empty)
+	TrustedProxies []string `env:"API_TRUSTED_PROXIES"`                  // IPs/CIDR ranges of proxies whose X-Forwarded-For header is trusted for the client IP
+	MaxBodyBytes   int      `env:"API_MAX_BODY_BYTES" default:"1048576"` // Largest request body accepted, anything bigger gets a 413 (no limit if zero)
+
+	// HTTP server timeouts (none if zero)
+	ReadTimeout       time.Duration `env:"API_READ_TIMEOUT" default:"10s"`       // Reading the whole request, including the body
+	ReadHeaderTimeout time.Duration `env:"API_READ_HEADER_TIMEOUT" default:"5s"` // Reading the request headers
+	WriteTimeout      time.Duration `env:"API_WRITE_TIMEOUT" default:"30s"`      // From the end of the headers to the end of the response
+	IdleTimeout       time.Duration `env:"API_IDLE_TIMEOUT" default:"2m"`        // Keep-alive connections waiting for the next request
+	ShutdownTimeout   time.Duration `env:"API_SHUTDOWN_TIMEOUT" default:"10s"`   // How long in-flight requests get to finish on shutdown
+	ShutdownDelay     time.Duration `env:"API_SHUTDOWN_DELAY" default:"0s"`      // How long to keep serving (while /readyz reports not ready) before shutting down
+
+	// Health checks
+	ReadyTimeout time.Duration `env:"API_READY_TIMEOUT" default:"2s"` // How long /readyz waits for its checks
+}
+
+type ConfMonitor struct {
+	Window        time.Duration `env:"MONITOR_WINDOW" default:"1m"`          // Sliding window 4xx responses are counted over
+	IPThreshold   int           `env:"MONITOR_IP_THRESHOLD" default:"100"`   // 4xx responses per window before a client IP is flagged (0 to disable)
+	KeyThreshold  int           `env:"MONITOR_KEY_THRESHOLD" default:"50"`   // 4xx responses per window before an API key is flagged (0 to disable)
+	Block         bool          `env:"MONITOR_BLOCK" default:"false"`        // Refuse requests from flagged clients
+	BlockDuration time.Duration `env:"MONITOR_BLOCK_DURATION" default:"10m"` // How long flagged clients are blocked for
+}
+
+type ConfRateLimit struct {
+	Driver string `env:"RATE_LIMIT_DRIVER" default:"memory"` // "none", "memory" or "redis" (shared between API instances, uses the REDIS_* settings)
+	Read   string `env:"RATE_LIMIT_READ" default:"600/1m"`   // Other requests to /api/, as limit/period ("0" for no limit)
+	Write  string `env:"RATE_LIMIT_WRITE" default:"60/1m"`   // POST, PUT, PATCH and DELETE requests to /api/
+	Signup string `env:"RATE_LIMIT_SIGNUP" default:"10/1h"`  // Creating Users (POST /api/v1/users)
+
+	UnknownKey string `env:"RATE_LIMIT_UNKNOWN_KEY" default:"30/1m"` // API keys that don't belong to any User, looked up per IP
 }
 
 type ConfDatabase struct {
-	Driver       string
-	Host         string
-	Port         string
-	Username     string
-	Password     string
-	DatabaseName string
-	SSLMode      string
+	Driver       string `env:"DATABASE_DRIVER" default:"postgresql"` // "postgresql" (or "postgres") or "sqlite"
+	URL          string `env:"DATABASE_URL"`                         // Full Postgres connection URL, used instead of the separate settings below
+	Host         string `env:"DATABASE_HOST"`
+	Port         string `env:"DATABASE_PORT"`
+	Username     string `env:"DATABASE_USER"`
+	Password     string `env:"DATABASE_PASS" secret:"true"`
+	DatabaseName string `env:"DATABASE_NAME"` // Path to the database file when using SQLite
+	SSLMode      string `env:"DATABASE_SSL"`
+
+	// Extra Postgres connection parameters, added to DATABASE_URL unless it already has them
+	ApplicationName string        `env:"DATABASE_APPLICATION_NAME" default:"qlikrestapi"` // Shown in pg_stat_activity
+	ConnectTimeout  time.Duration `env:"DATABASE_CONNECT_TIMEOUT" default:"10s"`          // Rounded up to whole seconds, none if zero
+	SSLRootCert     string        `env:"DATABASE_SSL_ROOT_CERT"`                          // CA certificate used to verify the server
+	SSLCert         string        `env:"DATABASE_SSL_CERT"`                               // Client certificate
+	SSLKey          string        `env:"DATABASE_SSL_KEY"`                                // Client certificate key
+
+	QueryTimeout time.Duration `env:"DATABASE_QUERY_TIMEOUT" default:"5s"` // Queries running longer than this are aborted, none if zero
+	Replicas     []string      `env:"DATABASE_REPLICAS"`                   // Connection strings of read replicas (optional)
+	Migrations   string        `env:"DATABASE_MIGRATIONS_DIR"`             // Directory to read migrations from, or empty to use the embedded ones
+	AutoMigrate  bool          `env:"AUTO_MIGRATE" default:"true"`         // Run pending migrations when the API starts
+
+	// Connection pool
+	MaxOpenConns    int           `env:"DATABASE_MAX_OPEN_CONNS" default:"25"`
+	MaxIdleConns    int           `env:"DATABASE_MAX_IDLE_CONNS" default:"5"`
+	ConnMaxLifetime time.Duration `env:"DATABASE_CONN_MAX_LIFETIME" default:"30m"`
+	ConnMaxIdleTime time.Duration `env:"DATABASE_CONN_MAX_IDLE_TIME" default:"5m"`
+
+	// Startup health check
+	ConnectAttempts int           `env:"DATABASE_CONNECT_ATTEMPTS" default:"10"`   // How many times to try reaching the database on startup
+	ConnectBackoff  time.Duration `env:"DATABASE_CONNECT_BACKOFF" default:"500ms"` // Wait before the first retry, doubled after each attempt
+}
+
+type ConfMailer struct {
+	Driver    string `env:"MAILER_DRIVER" default:"file"`      // "file" or "smtp"
+	Directory string `env:"MAILER_DIRECTORY" default:"./mail"` // Used by the "file" driver
+	Host      string `env:"MAILER_HOST" default:"localhost"`
+	Port      string `env:"MAILER_PORT" default:"1025"`
+	Username  string `env:"MAILER_USER"`
+	Password  string `env:"MAILER_PASS" secret:"true"`
+	From      string `env:"MAILER_FROM" default:"no-reply@localhost"`
+}
+
+type ConfVerification struct {
+	Enabled bool          `env:"EMAIL_VERIFICATION"`
+	Secret  string        `env:"EMAIL_VERIFICATION_SECRET" secret:"true"` // Required when enabled
+	URL     string        `env:"EMAIL_VERIFICATION_URL"`                  // Base URL used to build the link sent to users (defaults to http://localhost:API_PORT)
+	TTL     time.Duration `env:"EMAIL_VERIFICATION_TTL" default:"24h"`
+}
+
+type ConfCache struct {
+	Driver string        `env:"CACHE_DRIVER" default:"memory"` // "none", "memory" or "redis"
+	Size   int           `env:"CACHE_SIZE" default:"10000"`    // Maximum entries kept by the "memory" driver
+	TTL    time.Duration `env:"CACHE_TTL" default:"1m"`
+}
+
+type ConfRedis struct {
+	Addr     string        `env:"REDIS_ADDR" default:"localhost:6379"` // Host and port (ex: localhost:6379)
+	Password string        `env:"REDIS_PASSWORD" secret:"true"`
+	DB       int           `env:"REDIS_DB" default:"0"`
+	Timeout  time.Duration `env:"REDIS_TIMEOUT" default:"500ms"`
 }
 
-// TODO: Use struct tags to minimize manual effort (or just use an env management tool)
+// Load the config, exiting with every problem found if it isn't valid. See Load.
 func New() *Conf {
+	return NewFromFile("")
+}
+
+// Load the config with a specific config file, exiting with every problem found if it
+// isn't valid. See LoadFile.
+func NewFromFile(path string) *Conf {
+	config, err := LoadFile(path)
+	if err != nil {
+		log.Fatalf("Invalid config:\n%v\n", err)
+	}
+	return config
+}
+
+// Load the config from the environment (and a .env file, if there is one), on top of the
+// config file named by CONFIG_FILE, if set. Every problem found is returned together,
+// rather than stopping at the first one.
+func Load() (*Conf, error) {
+	return LoadFile("")
+}
+
+// Load the config like Load, but from the config file at path (if not empty) instead of
+// the one named by CONFIG_FILE. Environment variables override values from the file.
+func LoadFile(path string) (*Conf, error) {
 	godotenv.Load()
+	if len(path) == 0 {
+		path = os.Getenv("CONFIG_FILE")
+	}
 
-	var config Conf
-	config.General = &ConfGeneral{
-		Tag: lookup("TAG"),
+	var file map[string]string
+	if len(path) > 0 {
+		var err error
+		if file, err = readConfigFile(path); err != nil {
+			return nil, err
+		}
 	}
-	config.API = &ConfAPI{
-		Port: lookup("API_PORT"),
+	return load(os.LookupEnv, file)
+}
+
+func load(lookupEnv func(key string) (string, bool), file map[string]string) (*Conf, error) {
+	src := &source{lookupEnv: lookupEnv, file: file}
+
+	var config Conf
+	errs := loadStruct(reflect.ValueOf(&config).Elem(), src.lookup)
+	if len(errs) == 0 {
+		errs = config.validate(src.lookup)
 	}
-	config.Database = &ConfDatabase{
-		Driver:       lookup("DATABASE_DRIVER"),
-		Host:         lookup("DATABASE_HOST"),
-		Port:         lookup("DATABASE_PORT"),
-		Username:     lookup("DATABASE_USER"),
-		Password:     lookup("DATABASE_PASS"),
-		DatabaseName: lookup("DATABASE_NAME"),
-		SSLMode:      lookup("DATABASE_SSL"),
+	errs = append(src.errs, errs...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
 	}
-	return &config
+	return &config, nil
 }
 
-func lookup(key string) string {
-	val, ok := os.LookupEnv(key)
-	if !ok {
-		log.Fatalf("Environment variable `%s` is missing\n", key)
+// Check the settings that depend on each other, which can't be described with tags.
+func (c *Conf) validate(lookupEnv func(key string) (string, bool)) []error {
+	var errs []error
+
+	if c.API.Port < 1 || c.API.Port > 65535 {
+		errs = append(errs, fmt.Errorf("environment variable `API_PORT` must be a port number (1-65535), got `%d`", c.API.Port))
+	}
+	errs = append(errs, c.Log.validate()...)
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("environment variable `TRACING_EXPORTER` must be none, stdout or otlp, got `%s`", c.Tracing.Exporter))
+	}
+	errs = append(errs, c.Database.validate(lookupEnv)...)
+	errs = append(errs, c.RateLimit.validate()...)
+
+	if c.Verification.Enabled && len(c.Verification.Secret) == 0 {
+		errs = append(errs, errors.New("environment variable `EMAIL_VERIFICATION_SECRET` is required when EMAIL_VERIFICATION is enabled"))
+	}
+	if len(c.Verification.URL) == 0 {
+		c.Verification.URL = "http://localhost:" + strconv.Itoa(c.API.Port)
 	}
-	return val
+	return errs
 }
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Look up environment variables from a map instead of the process environment.
+func envMap(env map[string]string) func(key string) (string, bool) {
+	return func(key string) (string, bool) {
+		val, ok := env[key]
+		return val, ok
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	c, err := load(envMap(map[string]string{
+		"TAG":             "v1",
+		"API_PORT":        "8080",
+		"DATABASE_DRIVER": "sqlite",
+		"DATABASE_NAME":   "./qlik.db",
+	}), nil)
+	if err != nil {
+		t.Fatalf("load() = %v, should load with only the required variables", err)
+	}
+
+	if c.Database.QueryTimeout != 5*time.Second || c.Database.MaxOpenConns != 25 || !c.Database.AutoMigrate {
+		t.Errorf("database defaults = %+v, want 5s query timeout, 25 open conns and auto migrate", c.Database)
+	}
+	if c.Cache.Driver != "memory" || c.Cache.Size != 10000 || c.Redis.Timeout != 500*time.Millisecond {
+		t.Errorf("cache defaults = %+v %+v", c.Cache, c.Redis)
+	}
+	if c.Verification.URL != "http://localhost:8080" {
+		t.Errorf("Verification.URL = %q, should default to the API port", c.Verification.URL)
+	}
+}
+
+func TestLoadTypes(t *testing.T) {
+	c, err := load(envMap(map[string]string{
+		"TAG":                     "v1",
+		"API_PORT":                "8080",
+		"DATABASE_DRIVER":         "sqlite",
+		"DATABASE_NAME":           "./qlik.db",
+		"DATABASE_REPLICAS":       " file:a.db ,, file:b.db",
+		"DATABASE_MAX_OPEN_CONNS": "3",
+		"DATABASE_QUERY_TIMEOUT":  "250ms",
+		"AUTO_MIGRATE":            "false",
+		"CACHE_TTL":               "", // Empty uses the default
+	}), nil)
+	if err != nil {
+		t.Fatalf("load() = %v", err)
+	}
+
+	if want := []string{"file:a.db", "file:b.db"}; !reflect.DeepEqual(c.Database.Replicas, want) {
+		t.Errorf("Replicas = %v, want %v", c.Database.Replicas, want)
+	}
+	if c.Database.MaxOpenConns != 3 || c.Database.QueryTimeout != 250*time.Millisecond || c.Database.AutoMigrate {
+		t.Errorf("database = %+v, want 3 open conns, 250ms query timeout and no auto migrate", c.Database)
+	}
+	if c.Cache.TTL != time.Minute {
+		t.Errorf("Cache.TTL = %s, want the default 1m", c.Cache.TTL)
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	_, err := load(envMap(map[string]string{
+		"DATABASE_DRIVER":         "postgresql",
+		"DATABASE_NAME":           "postgres",
+		"DATABASE_MAX_OPEN_CONNS": "lots",
+		"CACHE_TTL":               "forever",
+		"EMAIL_VERIFICATION":      "maybe",
+	}), nil)
+	if err == nil {
+		t.Fatal("load() should fail")
+	}
+
+	for _, want := range []string{"`TAG` is missing", "`API_PORT` is missing", "`DATABASE_MAX_OPEN_CONNS` must be a whole number",
+		"`CACHE_TTL` must be a duration", "`EMAIL_VERIFICATION` must be true or false"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("load() = %q, should contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadConditionalRequirements(t *testing.T) {
+	_, err := load(envMap(map[string]string{
+		"TAG":                "v1",
+		"API_PORT":           "80800",
+		"DATABASE_DRIVER":    "postgresql",
+		"DATABASE_NAME":      "postgres",
+		"EMAIL_VERIFICATION": "true",
+		"LOG_LEVEL":          "loud",
+	}), nil)
+	if err == nil {
+		t.Fatal("load() should fail")
+	}
+
+	for _, want := range []string{"`DATABASE_HOST` is missing", "`DATABASE_PASS` is missing", "`EMAIL_VERIFICATION_SECRET` is required", "`LOG_LEVEL` must be",
+		"`API_PORT` must be a port number"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("load() = %q, should contain %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Parameters added to the "file:path?..." SQLite URI: foreign keys enforced (off by default in
+// SQLite) and a busy timeout so concurrent writers wait for the lock instead of failing.
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// Get the database/sql driver name for the selected database.
+func (c *ConfDatabase) DriverName() string {
+	if c.IsSQLite() {
+		return "sqlite"
+	}
+	return "postgres"
+}
+
+// Build the connection string for the primary database. For Postgres this is DATABASE_URL
+// (if set) or a URL built from the separate settings with every part escaped, so passwords
+// can contain characters like @ and /.
+func (c *ConfDatabase) ConnectionString() string {
+	if c.IsSQLite() {
+		// Escape the path, so characters like ? and # are part of the file name (SQLite
+		// decodes them when opening the file).
+		path := (&url.URL{Path: c.DatabaseName}).EscapedPath()
+		return (&url.URL{Scheme: "file", Opaque: path, RawQuery: sqlitePragmas}).String()
+	}
+	u, err := c.postgresURL()
+	if err != nil {
+		// Can't happen once the config has been validated.
+		return c.URL
+	}
+	return u.String()
+}
+
+// Build the connection strings for the read replicas. Postgres replicas get the same extra
+// parameters as the primary (ex: sslmode, application_name) unless their URL sets them itself.
+func (c *ConfDatabase) ReplicaConnectionStrings() []string {
+	if c.IsSQLite() {
+		return c.Replicas
+	}
+	connStrs := make([]string, len(c.Replicas))
+	for i, replica := range c.Replicas {
+		u, err := c.replicaURL(i, replica)
+		if err != nil {
+			// Can't happen once the config has been validated.
+			connStrs[i] = replica
+			continue
+		}
+		connStrs[i] = u.String()
+	}
+	return connStrs
+}
+
+// Check if the SQLite driver is selected.
+func (c *ConfDatabase) IsSQLite() bool {
+	return c.Driver == "sqlite"
+}
+
+func (c *ConfDatabase) postgresURL() (*url.URL, error) {
+	u := &url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(c.Username, c.Password),
+		Host:   net.JoinHostPort(c.Host, c.Port),
+		Path:   "/" + c.DatabaseName,
+	}
+	if len(c.URL) > 0 {
+		var err error
+		// The parse error isn't included, since it contains the URL (and password).
+		if u, err = url.Parse(c.URL); err != nil {
+			return nil, errors.New("environment variable `DATABASE_URL` is not a valid URL")
+		}
+		if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+			return nil, errors.New("environment variable `DATABASE_URL` must start with postgres:// or postgresql://")
+		}
+	}
+	c.addParams(u)
+	return u, nil
+}
+
+func (c *ConfDatabase) replicaURL(i int, replica string) (*url.URL, error) {
+	// The parse error isn't included, since it contains the URL (and password).
+	u, err := url.Parse(replica)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, fmt.Errorf("environment variable `DATABASE_REPLICAS` item %d must be a postgres:// or postgresql:// URL", i+1)
+	}
+	c.addParams(u)
+	return u, nil
+}
+
+// Add the extra connection parameters to u, without replacing any that are already in it.
+func (c *ConfDatabase) addParams(u *url.URL) {
+	params := map[string]string{
+		"sslmode":          c.SSLMode,
+		"application_name": c.ApplicationName,
+		"sslrootcert":      c.SSLRootCert,
+		"sslcert":          c.SSLCert,
+		"sslkey":           c.SSLKey,
+	}
+	if c.ConnectTimeout > 0 {
+		params["connect_timeout"] = strconv.Itoa(int(math.Ceil(c.ConnectTimeout.Seconds())))
+	}
+	query := u.Query()
+	for key, val := range params {
+		if len(val) > 0 && !query.Has(key) {
+			query.Set(key, val)
+		}
+	}
+	u.RawQuery = query.Encode()
+}
+
+// Check the database settings. SQLite only needs a file name, and Postgres needs either
+// DATABASE_URL or all of the separate connection settings.
+func (c *ConfDatabase) validate(lookupEnv func(key string) (string, bool)) []error {
+	switch c.Driver {
+	case "sqlite":
+		if len(c.DatabaseName) == 0 {
+			return []error{errors.New("environment variable `DATABASE_NAME` is missing")}
+		}
+		return nil
+	case "postgresql", "postgres":
+	default:
+		return []error{fmt.Errorf("environment variable `DATABASE_DRIVER` must be postgresql or sqlite, got `%s`", c.Driver)}
+	}
+
+	var errs []error
+	for i, replica := range c.Replicas {
+		if _, err := c.replicaURL(i, replica); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(c.URL) > 0 {
+		u, err := c.postgresURL()
+		if err != nil {
+			return append(errs, err)
+		}
+		// The database name is still needed by the migrator.
+		if len(c.DatabaseName) == 0 {
+			c.DatabaseName = strings.TrimPrefix(u.Path, "/")
+		}
+		return errs
+	}
+
+	for _, key := range []string{"DATABASE_HOST", "DATABASE_PORT", "DATABASE_USER", "DATABASE_PASS", "DATABASE_NAME", "DATABASE_SSL"} {
+		if _, ok := lookupEnv(key); !ok {
+			errs = append(errs, fmt.Errorf("environment variable `%s` is missing", key))
+		}
+	}
+	if port, err := strconv.Atoi(c.Port); len(c.Port) > 0 && (err != nil || port < 1 || port > 65535) {
+		errs = append(errs, fmt.Errorf("environment variable `DATABASE_PORT` must be a port number (1-65535), got `%s`", c.Port))
+	}
+	return errs
+}
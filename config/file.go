@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Where setting values come from, in order of priority:
+//  1. The environment variable (ex: DATABASE_PASS)
+//  2. A file named by the environment variable with a _FILE suffix (ex: DATABASE_PASS_FILE),
+//     for Docker/Kubernetes secrets
+//  3. The config file, if there is one (a _FILE key there works the same way)
+//  4. The default from the struct tag
+type source struct {
+	lookupEnv func(key string) (string, bool)
+	file      map[string]string // Values from the config file, keyed by environment variable name
+	errs      []error           // Problems reading secret files
+}
+
+func (s *source) lookup(key string) (string, bool) {
+	if val, ok := s.lookupEnv(key); ok {
+		return val, true
+	}
+	if path, ok := s.lookupEnv(key + "_FILE"); ok {
+		return s.readSecret(key, path)
+	}
+	if val, ok := s.file[key]; ok {
+		return val, true
+	}
+	if path, ok := s.file[key+"_FILE"]; ok {
+		return s.readSecret(key, path)
+	}
+	return "", false
+}
+
+// Read a secret from a file, without the trailing newline most editors and tools add.
+func (s *source) readSecret(key string, path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("unable to read `%s_FILE`: %w", key, err))
+		return "", false
+	}
+	return strings.TrimRight(string(data), "\r\n"), true
+}
+
+// Read a YAML (.yaml, .yml) or TOML (.toml) config file into values keyed by environment
+// variable name. Nested keys are joined with underscores and upper-cased, so both
+// `DATABASE_HOST: postgres` and a `database:` section containing `host: postgres` set
+// DATABASE_HOST. Lists are joined with commas.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("unsupported config file type `%s`, use .yaml, .yml or .toml", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten(values, "", tree)
+	return values, nil
+}
+
+func flatten(values map[string]string, prefix string, tree map[string]any) {
+	for key, val := range tree {
+		key = strings.ToUpper(key)
+		if len(prefix) > 0 {
+			key = prefix + "_" + key
+		}
+
+		switch val := val.(type) {
+		case map[string]any:
+			flatten(values, key, val)
+		case []any:
+			items := make([]string, len(val))
+			for i, item := range val {
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(val)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fill the fields of a struct from the environment, based on their tags:
+//   - env: Name of the environment variable
+//   - default: Value used when the variable is missing
+//   - required: "true" if the variable must be set (it may still be empty)
+//   - secret: "true" if the value must not be shown (see Print)
+//
+// Setting a variable to an empty value uses the default, unless the field is a string.
+//
+// Pointers to structs are allocated and filled in the same way. Supported types are
+// string, bool, int, time.Duration and []string (comma separated, empty items dropped).
+// Every problem found is returned, rather than stopping at the first one.
+func loadStruct(v reflect.Value, lookupEnv func(key string) (string, bool)) []error {
+	var errs []error
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field, value := t.Field(i), v.Field(i)
+
+		// Fill in nested config sections.
+		if field.Type.Kind() == reflect.Pointer && field.Type.Elem().Kind() == reflect.Struct {
+			value.Set(reflect.New(field.Type.Elem()))
+			errs = append(errs, loadStruct(value.Elem(), lookupEnv)...)
+			continue
+		}
+
+		key, ok := field.Tag.Lookup("env")
+		if !ok {
+			continue
+		}
+		raw, ok := lookupEnv(key)
+		if !ok && field.Tag.Get("required") == "true" {
+			errs = append(errs, fmt.Errorf("environment variable `%s` is missing", key))
+			continue
+		}
+		// Only strings can be set to empty, other types use their default instead.
+		if !ok || (len(raw) == 0 && value.Kind() != reflect.String) {
+			raw = field.Tag.Get("default")
+		}
+		if err := setField(value, raw); err != nil {
+			errs = append(errs, fmt.Errorf("environment variable `%s` %v", key, err))
+		}
+	}
+	return errs
+}
+
+// Parse raw into the field's type and store it.
+func setField(value reflect.Value, raw string) error {
+	switch value.Interface().(type) {
+	case string:
+		value.SetString(raw)
+	case bool:
+		if len(raw) == 0 {
+			value.SetBool(false)
+			return nil
+		}
+		val, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be true or false, got `%s`", raw)
+		}
+		value.SetBool(val)
+	case int:
+		if len(raw) == 0 {
+			value.SetInt(0)
+			return nil
+		}
+		val, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("must be a whole number, got `%s`", raw)
+		}
+		value.SetInt(int64(val))
+	case time.Duration:
+		if len(raw) == 0 {
+			value.SetInt(0)
+			return nil
+		}
+		val, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("must be a duration (ex: 24h), got `%s`", raw)
+		}
+		value.SetInt(int64(val))
+	case []string:
+		var list []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); len(item) > 0 {
+				list = append(list, item)
+			}
+		}
+		value.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("has an unsupported type %s", value.Type())
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+)
+
+// Create a new logger that writes to w with the configured format and level.
+func (c *ConfLog) NewLogger(w io.Writer) *slog.Logger {
+	var level slog.Level
+	level.UnmarshalText([]byte(c.Level))
+
+	opts := &slog.HandlerOptions{Level: level}
+	if c.Format == "json" {
+		return slog.New(slog.NewJSONHandler(w, opts))
+	}
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+func (c *ConfLog) validate() []error {
+	var errs []error
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("environment variable `LOG_LEVEL` must be debug, info, warn or error, got `%s`", c.Level))
+	}
+	if c.Format != "text" && c.Format != "json" {
+		errs = append(errs, fmt.Errorf("environment variable `LOG_FORMAT` must be text or json, got `%s`", c.Format))
+	}
+	switch c.AccessFormat {
+	case "none", "common", "combined", "json":
+	default:
+		errs = append(errs, fmt.Errorf("environment variable `ACCESS_LOG_FORMAT` must be none, common, combined or json, got `%s`", c.AccessFormat))
+	}
+	return errs
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/agnate/qlikrestapi/internal/ratelimit"
+)
+
+// Get the rates of the read, write and signup rate limit groups, and of unknown API keys
+// looked up per IP. Zero rates aren't limited.
+func (c *ConfRateLimit) Rates() (read ratelimit.Rate, write ratelimit.Rate, signup ratelimit.Rate, unknownKey ratelimit.Rate) {
+	read, _ = ratelimit.ParseRate(c.Read)
+	write, _ = ratelimit.ParseRate(c.Write)
+	signup, _ = ratelimit.ParseRate(c.Signup)
+	unknownKey, _ = ratelimit.ParseRate(c.UnknownKey)
+	return
+}
+
+func (c *ConfRateLimit) validate() []error {
+	var errs []error
+	switch c.Driver {
+	case "none", "memory", "redis":
+	default:
+		errs = append(errs, fmt.Errorf("environment variable `RATE_LIMIT_DRIVER` must be none, memory or redis, got `%s`", c.Driver))
+	}
+	for _, rate := range []struct{ key, value string }{
+		{"RATE_LIMIT_READ", c.Read},
+		{"RATE_LIMIT_WRITE", c.Write},
+		{"RATE_LIMIT_SIGNUP", c.Signup},
+		{"RATE_LIMIT_UNKNOWN_KEY", c.UnknownKey},
+	} {
+		if _, err := ratelimit.ParseRate(rate.value); err != nil {
+			errs = append(errs, fmt.Errorf("environment variable `%s`: %w", rate.key, err))
+		}
+	}
+	return errs
+}
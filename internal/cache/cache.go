@@ -0,0 +1,74 @@
+// Key/value caches used to avoid repeating database lookups. Values are raw bytes, so the
+// same data can be kept in-process (LRU) or shared between API instances (Redis).
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"time"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+)
+
+type Cache interface {
+	// Get the value stored for key. Returns false if it is missing or has expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Store a value for key, which expires after ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Remove the values stored for keys, if any.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Encode v for storage in a Cache. Gob is used rather than JSON so that fields hidden from
+// API output (ex: `json:"-"`) are kept.
+func Encode(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode a value created by Encode into v.
+func Decode(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// Get the value cached for key, decoded into a new T, or nil if it isn't cached. Cache
+// errors are logged and treated as a miss, so the cache being down never stops the API
+// from working.
+func Load[T any](ctx context.Context, c Cache, key string) *T {
+	data, ok, err := c.Get(ctx, key)
+	if err != nil {
+		myCtx.GetLogger(ctx).Warn("cache error", "error", err)
+	}
+	if !ok {
+		return nil
+	}
+
+	v := new(T)
+	if err := Decode(data, v); err != nil {
+		myCtx.GetLogger(ctx).Warn("cache error", "error", err)
+		return nil
+	}
+	return v
+}
+
+// Cache v for key until ttl expires. Errors are logged, like in Load.
+func Save(ctx context.Context, c Cache, key string, v any, ttl time.Duration) {
+	data, err := Encode(v)
+	if err == nil {
+		err = c.Set(ctx, key, data, ttl)
+	}
+	if err != nil {
+		myCtx.GetLogger(ctx).Warn("cache error", "error", err)
+	}
+}
+
+// Remove the values cached for keys. Errors are logged, like in Load.
+func Invalidate(ctx context.Context, c Cache, keys ...string) {
+	if err := c.Delete(ctx, keys...); err != nil {
+		myCtx.GetLogger(ctx).Warn("cache error", "error", err)
+	}
+}
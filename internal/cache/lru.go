@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Thread-safe, in-process cache that holds up to a fixed number of entries. When it is full,
+// the least recently used entry is evicted.
+type LRU struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List // Most recently used at the front
+	now     func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// Create a new LRU cache holding up to size entries.
+func NewLRU(size int) *LRU {
+	return &LRU{
+		size:    max(size, 1),
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+func (c *LRU) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := el.Value.(*lruEntry)
+	if !c.now().Before(entry.expires) {
+		c.remove(el)
+		return nil, false, nil
+	}
+	c.order.MoveToFront(el)
+	return entry.value, true, nil
+}
+
+func (c *LRU) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(ttl)
+	if el, ok := c.entries[key]; ok {
+		entry := el.Value.(*lruEntry)
+		entry.value = value
+		entry.expires = expires
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, value: value, expires: expires})
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Delete(ctx context.Context, keys ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := c.entries[key]; ok {
+			c.remove(el)
+		}
+	}
+	return nil
+}
+
+func (c *LRU) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry).key)
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+
+	// Using "a" makes "b" the least recently used.
+	c.Get(ctx, "a")
+	c.Set(ctx, "c", []byte("3"), time.Minute)
+
+	if _, ok, _ := c.Get(ctx, "b"); ok {
+		t.Errorf("Get(b) should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok, _ := c.Get(ctx, key); !ok {
+			t.Errorf("Get(%s) should still be cached", key)
+		}
+	}
+}
+
+func TestLRUExpires(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 6, 5, 0, 0, 0, 0, time.UTC)
+	c := NewLRU(10)
+	c.now = func() time.Time { return now }
+
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	if value, ok, _ := c.Get(ctx, "a"); !ok || string(value) != "1" {
+		t.Fatalf("Get(a) = %s, %t, should be cached", value, ok)
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Errorf("Get(a) should have expired")
+	}
+}
+
+func TestLRUDelete(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(10)
+	c.Set(ctx, "a", []byte("1"), time.Minute)
+	c.Set(ctx, "b", []byte("2"), time.Minute)
+
+	c.Delete(ctx, "a", "b", "missing")
+	for _, key := range []string{"a", "b"} {
+		if _, ok, _ := c.Get(ctx, key); ok {
+			t.Errorf("Get(%s) should have been deleted", key)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache backed by Redis (or anything that speaks the Redis protocol, ex: a local stand-in
+// like KeyDB or Dragonfly), so cached data is shared between API instances. Eval is
+// provided for anything Cache doesn't cover.
+type Redis struct {
+	client *redis.Client
+}
+
+// Create a new Redis cache. Connections are opened when first needed.
+//
+// # Parameters
+//   - addr: Host and port of the server (ex: localhost:6379)
+//   - password: Sent with AUTH when not empty
+//   - db: Database number to SELECT
+//   - timeout: How long connecting, or sending or reading a command, can take
+func NewRedis(addr string, password string, db int, timeout time.Duration) *Redis {
+	return &Redis{
+		client: redis.NewClient(&redis.Options{
+			Addr:         addr,
+			Password:     password,
+			DB:           db,
+			DialTimeout:  timeout,
+			ReadTimeout:  timeout,
+			WriteTimeout: timeout,
+		}),
+	}
+}
+
+func (c *Redis) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *Redis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, key, value, max(ttl, time.Millisecond)).Err()
+}
+
+func (c *Redis) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return c.client.Del(ctx, keys...).Err()
+}
+
+// Run a Lua script on the server, so it can read and change keys atomically (ex: for rate
+// limiting). The script is sent in full only the first time, then run by its SHA1. Returns
+// the script's reply: strings as string, integers as int64 and tables as []any.
+func (c *Redis) Eval(ctx context.Context, script *redis.Script, keys []string, args ...any) (any, error) {
+	return script.Run(ctx, c.client, keys, args...).Result()
+}
+
+// Check the server can be reached.
+func (c *Redis) Ping(ctx context.Context) error {
+	return c.client.Ping(ctx).Err()
+}
+
+// Close the connection pool.
+func (c *Redis) Close() error {
+	return c.client.Close()
+}
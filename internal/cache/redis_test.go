@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Start an in-process Redis server, stopped when the test ends.
+func newTestRedisServer(t *testing.T, password string) string {
+	server := miniredis.RunT(t)
+	if len(password) > 0 {
+		server.RequireAuth(password)
+	}
+	return server.Addr()
+}
+
+func TestRedisGetSetDelete(t *testing.T) {
+	ctx := context.Background()
+	c := NewRedis(newTestRedisServer(t, "secret"), "secret", 0, time.Second)
+	defer c.Close()
+
+	if _, ok, err := c.Get(ctx, "a"); ok || err != nil {
+		t.Fatalf("Get(a) = %t, %v, should be missing", ok, err)
+	}
+
+	// Values are binary safe.
+	value := []byte("line 1\r\nline 2\x00")
+	if err := c.Set(ctx, "a", value, time.Minute); err != nil {
+		t.Fatalf("an error '%s' was not expected while setting a value", err)
+	}
+	if got, ok, err := c.Get(ctx, "a"); !ok || err != nil || string(got) != string(value) {
+		t.Fatalf("Get(a) = %q, %t, %v, should be %q", got, ok, err, value)
+	}
+
+	if err := c.Delete(ctx, "a", "missing"); err != nil {
+		t.Fatalf("an error '%s' was not expected while deleting a value", err)
+	}
+	if _, ok, _ := c.Get(ctx, "a"); ok {
+		t.Errorf("Get(a) should have been deleted")
+	}
+}
+
+func TestRedisWrongPassword(t *testing.T) {
+	c := NewRedis(newTestRedisServer(t, "secret"), "wrong", 0, time.Second)
+	defer c.Close()
+
+	if err := c.Ping(context.Background()); err == nil {
+		t.Errorf("Ping() should return the server's error")
+	}
+}
+
+func TestRedisEval(t *testing.T) {
+	c := NewRedis(newTestRedisServer(t, ""), "", 0, time.Second)
+	defer c.Close()
+
+	script := redis.NewScript(`return {KEYS[1], ARGV[1]}`)
+	reply, err := c.Eval(context.Background(), script, []string{"key"}, "arg")
+	if err != nil {
+		t.Fatalf("Eval() = %v", err)
+	}
+	items, ok := reply.([]any)
+	if !ok || len(items) != 2 || items[0] != "key" || items[1] != "arg" {
+		t.Errorf("Eval() = %v, want the key and argument", reply)
+	}
+}
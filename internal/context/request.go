@@ -0,0 +1,47 @@
+package context
+
+import (
+	"context"
+	"log/slog"
+)
+
+// Header used to send and echo the ID of a request.
+const RequestIDHeader = "X-Request-ID"
+
+type contextLoggerKey struct{}
+
+type contextRequestIDKey struct{}
+
+// Get the logger for the request, or the default logger if none was set.
+func GetLogger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextLoggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+func SetLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextLoggerKey{}, logger)
+}
+
+// Get the ID of the request, or an empty string if it doesn't have one.
+func GetRequestID(ctx context.Context) string {
+	id, _ := ctx.Value(contextRequestIDKey{}).(string)
+	return id
+}
+
+func SetRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextRequestIDKey{}, id)
+}
+
+type contextClientIPKey struct{}
+
+// Get the IP address of the client that made the request, or an empty string if it wasn't set.
+func GetClientIP(ctx context.Context) string {
+	ip, _ := ctx.Value(contextClientIPKey{}).(string)
+	return ip
+}
+
+func SetClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, contextClientIPKey{}, ip)
+}
@@ -0,0 +1,129 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Where and how an entity's storage (ex: MessageStorage) runs its queries. Storage embeds a
+// Binding and wraps WithTx, WithQueryTimeout and WithReplicas to return copies of itself, so
+// the same storage can be shared by a multi-entity transaction, given a timeout or pointed
+// at read replicas without changing how its queries are written.
+type Binding struct {
+	db       *sql.DB
+	replicas *Replicas     // Used for reads outside of a transaction
+	tx       *sql.Tx       // Set when bound to a transaction with WithTx
+	timeout  time.Duration // Per-query timeout, none if zero
+}
+
+// Create a new Binding that runs everything against db.
+func NewBinding(db *sql.DB) Binding {
+	return Binding{
+		db:       db,
+		replicas: NewReplicas(db),
+	}
+}
+
+// Get a copy that runs everything inside an existing transaction. The caller is
+// responsible for committing or rolling back the transaction.
+func (b Binding) WithTx(tx *sql.Tx) Binding {
+	b.tx = tx
+	return b
+}
+
+// Get a copy that aborts any query taking longer than timeout.
+func (b Binding) WithQueryTimeout(timeout time.Duration) Binding {
+	b.timeout = timeout
+	return b
+}
+
+// Get a copy that sends reads to the replicas (falling back to the primary). Writes, and
+// reads inside a transaction, always go to the primary.
+func (b Binding) WithReplicas(replicas ...*sql.DB) Binding {
+	b.replicas = NewReplicas(b.db, replicas...)
+	return b
+}
+
+// Run a read inside the bound transaction, or against a read replica if it isn't bound to one.
+func (b Binding) Read(ctx context.Context, fn func(q Querier) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+	return b.replicas.Read(ctx, fn)
+}
+
+// Run fn inside the bound transaction, or inside a new one if it isn't bound.
+func (b Binding) InTx(ctx context.Context, fn func(q Querier) error) error {
+	return b.Atomic(ctx, func(tx *sql.Tx) error {
+		return fn(tx)
+	})
+}
+
+// Run fn with the bound transaction, or a new one if it isn't bound, so several statements
+// (ex: through a copy of the storage bound with WithTx) are committed or rolled back together.
+func (b Binding) Atomic(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if b.tx != nil {
+		return fn(b.tx)
+	}
+	return WithTx(ctx, b.db, fn)
+}
+
+// Run fn with the query timeout applied. The query is traced (and logged if it fails) as
+// name (ex: "messages.update"), and the error is checked with CheckError.
+func (b Binding) Run(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, cancel := WithTimeout(ctx, b.timeout)
+	defer cancel()
+	ctx, op := StartQuery(ctx, name)
+
+	err := CheckError(ctx, fn(ctx))
+	op.End(err)
+	return err
+}
+
+// Run a statement that doesn't return rows inside a transaction (see Run).
+func (b Binding) Exec(ctx context.Context, name string, query string, queryParams ...any) error {
+	return b.Run(ctx, name, func(ctx context.Context) error {
+		return b.InTx(ctx, func(q Querier) error {
+			_, err := q.ExecContext(ctx, query, queryParams...)
+			return err
+		})
+	})
+}
+
+// Run a SELECT with Read and scan every row into a T (see Run).
+func Select[T any](ctx context.Context, b Binding, name string, cols Columns[T], query string, queryParams ...any) ([]*T, error) {
+	var rows []*T
+	err := b.Run(ctx, name, func(ctx context.Context) error {
+		return b.Read(ctx, func(q Querier) error {
+			result, err := q.QueryContext(ctx, query, queryParams...)
+			if err != nil {
+				return err
+			}
+			rows, err = ScanRows(result, cols)
+			return err
+		})
+	})
+	if err != nil {
+		return make([]*T, 0), err
+	}
+	return rows, nil
+}
+
+// Run an INSERT/UPDATE inside a transaction and scan the row it wrote, so the result can't
+// be affected by other changes made in the meantime (see Run). Returns sql.ErrNoRows when
+// the WHERE clause didn't match.
+func Write[T any](ctx context.Context, b Binding, name string, cols Columns[T], query string, queryParams ...any) (*T, error) {
+	var row *T
+	err := b.Run(ctx, name, func(ctx context.Context) error {
+		return b.InTx(ctx, func(q Querier) error {
+			var err error
+			row, err = ScanRow(q.QueryRowContext(ctx, query+" RETURNING "+cols.String(), queryParams...), cols)
+			return err
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return row, nil
+}
@@ -0,0 +1,63 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Maps a named column to the field of T it is scanned into.
+type Column[T any] struct {
+	Name  string
+	Field func(v *T) any // Returns a pointer to the field, as expected by Scan
+}
+
+// An ordered list of columns to read into T. Queries should name these columns explicitly
+// (instead of SELECT *), so columns added by later migrations don't break scanning.
+type Columns[T any] []Column[T]
+
+// Get the comma separated column names, for use in SELECT or RETURNING clauses.
+func (c Columns[T]) String() string {
+	names := make([]string, len(c))
+	for i, col := range c {
+		names[i] = col.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Get pointers to the fields of v in column order, for use with Scan.
+func (c Columns[T]) Fields(v *T) []any {
+	fields := make([]any, len(c))
+	for i, col := range c {
+		fields[i] = col.Field(v)
+	}
+	return fields
+}
+
+// Scan every row into a new T. The rows are always closed, and any scan or iteration
+// error is returned instead of a partial result.
+func ScanRows[T any](rows *sql.Rows, cols Columns[T]) ([]*T, error) {
+	defer rows.Close()
+
+	result := make([]*T, 0)
+	for rows.Next() {
+		v := new(T)
+		if err := rows.Scan(cols.Fields(v)...); err != nil {
+			return make([]*T, 0), fmt.Errorf("unable to scan row: %w", err)
+		}
+		result = append(result, v)
+	}
+	if err := rows.Err(); err != nil {
+		return make([]*T, 0), err
+	}
+	return result, nil
+}
+
+// Scan a single row into a new T. Returns sql.ErrNoRows (unwrapped) if there was no row.
+func ScanRow[T any](row *sql.Row, cols Columns[T]) (*T, error) {
+	v := new(T)
+	if err := row.Scan(cols.Fields(v)...); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
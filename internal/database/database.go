@@ -0,0 +1,40 @@
+// Shared helpers for running queries and transactions against a SQL database.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Anything that can run queries, such as *sql.DB or *sql.Tx.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Run fn inside a new transaction. The transaction is committed if fn returns nil,
+// otherwise (or if fn panics) it is rolled back.
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("unable to begin transaction: %w", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+			return
+		}
+		if err = tx.Commit(); err != nil {
+			err = fmt.Errorf("unable to commit transaction: %w", err)
+		}
+	}()
+
+	return fn(tx)
+}
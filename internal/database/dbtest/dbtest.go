@@ -0,0 +1,29 @@
+// Helpers for tests that need a real database.
+package dbtest
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/agnate/qlikrestapi/internal/migrator"
+)
+
+// Open a new SQLite database in a temporary directory with the SQLite migrations applied.
+// The database is closed when the test ends.
+func NewSQLite(t testing.TB) *sql.DB {
+	t.Helper()
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", "file:"+dbName+"?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrator.New("", dbName, "sqlite").Run(db); err != nil {
+		t.Fatalf("an error '%s' was not expected when applying the migrations", err)
+	}
+	return db
+}
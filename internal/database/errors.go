@@ -0,0 +1,53 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Returned (wrapped) when a query can't be run because the database can't be reached, or
+// the request was cancelled before it finished.
+var ErrUnavailable = errors.New("database unavailable")
+
+// Returned (wrapped) when a query takes longer than its timeout.
+var ErrTimeout = errors.New("database query timed out")
+
+// Get a copy of ctx that is cancelled after timeout, so a slow query is aborted. A timeout
+// of zero (or less) leaves ctx as it is.
+func WithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Wrap err with ErrTimeout or ErrUnavailable if it was caused by ctx ending or by a lost
+// connection. Drivers don't always return the context error when a query is cancelled
+// (ex: Postgres returns its own "canceling statement" error), so ctx is checked as well.
+func CheckError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	if errors.Is(err, context.Canceled) || ctx.Err() != nil || isConnectionError(err) {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	return err
+}
+
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.As(err, &netErr)
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Longest wait between connection attempts in Ping.
+const maxBackoff = 30 * time.Second
+
+// Connection pool settings. Zero values leave the database/sql defaults in place.
+type PoolConfig struct {
+	MaxOpenConns    int           // Maximum open connections (in use + idle)
+	MaxIdleConns    int           // Maximum idle connections kept around for reuse
+	ConnMaxLifetime time.Duration // Close connections after they have been open this long
+	ConnMaxIdleTime time.Duration // Close connections after they have been idle this long
+}
+
+// Apply the pool settings to db.
+func ConfigurePool(db *sql.DB, c PoolConfig) {
+	if c.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(c.MaxOpenConns)
+	}
+	if c.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(c.MaxIdleConns)
+	}
+	if c.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	}
+}
+
+// Check the database can be reached, trying up to attempts times. The wait between attempts
+// starts at backoff and doubles each time (up to 30s), which gives a database that is
+// starting at the same time as the API (ex: with docker compose) a chance to come up.
+func Ping(ctx context.Context, db *sql.DB, attempts int, backoff time.Duration) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = db.PingContext(ctx); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		myCtx.GetLogger(ctx).Warn("database is not ready, retrying", "attempt", attempt, "attempts", attempts, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+	return fmt.Errorf("unable to reach database after %d attempts: %w", attempts, err)
+}
+
+// Publish the pool statistics of db (open/idle connections, waits, etc.) on /metrics, labelled
+// with db_name=name.
+func PublishStats(name string, db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
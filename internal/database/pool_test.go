@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestPingRetriesUntilReady(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	// Fail twice before the database comes up.
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing().WillReturnError(errors.New("connection refused"))
+	mock.ExpectPing()
+
+	if err := Ping(context.Background(), db, 5, time.Millisecond); err != nil {
+		t.Errorf("Ping() = %v, should succeed on the third attempt", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPingGivesUp(t *testing.T) {
+	db, mock, err := sqlmock.New(sqlmock.MonitorPingsOption(true))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a stub database connection", err)
+	}
+	defer db.Close()
+
+	refused := errors.New("connection refused")
+	for i := 0; i < 3; i++ {
+		mock.ExpectPing().WillReturnError(refused)
+	}
+
+	if err := Ping(context.Background(), db, 3, time.Millisecond); !errors.Is(err, refused) {
+		t.Errorf("Ping() = %v, should return the last error after 3 attempts", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// A query being run, traced and (if it fails) logged under a name that tells it apart
+// from the others (ex: "users.create").
+type Query struct {
+	ctx   context.Context
+	name  string
+	start time.Time
+	span  trace.Span
+}
+
+// Start timing the query called name, in a span that is a child of the request's span.
+// The returned context should be used to run the query.
+func StartQuery(ctx context.Context, name string) (context.Context, *Query) {
+	ctx, span := tracing.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("db.operation.name", name)))
+	return ctx, &Query{ctx: ctx, name: name, start: time.Now(), span: span}
+}
+
+// Finish the query. A failed query is logged with its name, how long it ran for and the
+// ID of the request it was made for (through the request logger). sql.ErrNoRows is not
+// treated as a failure, since it only means nothing matched.
+func (q *Query) End(err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		err = nil
+	}
+	if err != nil {
+		myCtx.GetLogger(q.ctx).Error("database query failed", "query", q.name, "duration", time.Since(q.start), "error", err)
+	}
+	tracing.End(q.span, err)
+}
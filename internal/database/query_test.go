@@ -0,0 +1,34 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestQuerySpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	_, query := StartQuery(context.Background(), "users.get_by_uuid")
+	query.End(sql.ErrNoRows)
+	_, query = StartQuery(context.Background(), "users.create")
+	query.End(errors.New("duplicate key"))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].Name() != "users.get_by_uuid" || spans[0].Status().Code == codes.Error {
+		t.Errorf("span %q has status %v, no rows shouldn't be an error", spans[0].Name(), spans[0].Status())
+	}
+	if spans[1].Name() != "users.create" || spans[1].Status().Code != codes.Error {
+		t.Errorf("span %q has status %v, want an error", spans[1].Name(), spans[1].Status())
+	}
+}
@@ -0,0 +1,79 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+)
+
+// How long a replica is skipped after it couldn't be reached.
+const replicaCooldown = 30 * time.Second
+
+type primaryKey struct{}
+
+// Mark ctx so reads go to the primary rather than a replica. Used when the data must be up
+// to date (ex: checking a Message before changing it), since replicas may lag behind.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Check if ctx has been marked with WithPrimary.
+func UsePrimary(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
+
+// A primary database and its read replicas. Reads are spread across the replicas round
+// robin, falling back to the primary if none of them can be reached.
+type Replicas struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     atomic.Uint32
+}
+
+type replica struct {
+	db        *sql.DB
+	downUntil atomic.Int64 // Unix nano time until which the replica is skipped
+}
+
+// Create a new set of read replicas for primary. With no replicas, every read goes to the primary.
+func NewReplicas(primary *sql.DB, replicas ...*sql.DB) *Replicas {
+	r := &Replicas{
+		primary: primary,
+	}
+	for _, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db})
+	}
+	return r
+}
+
+// Run a read against a replica. If the replica can't be reached, it is skipped for a while
+// and the next one is tried, then finally the primary. Any other error (ex: a bad query,
+// which would fail on the primary too) is returned as it is, leaving the replica in use.
+// Reads go straight to the primary when ctx is marked with WithPrimary.
+func (r *Replicas) Read(ctx context.Context, fn func(q Querier) error) error {
+	if UsePrimary(ctx) || len(r.replicas) == 0 {
+		return fn(r.primary)
+	}
+
+	start := int(r.next.Add(1))
+	now := time.Now().UnixNano()
+	for i := range r.replicas {
+		rep := r.replicas[(start+i)%len(r.replicas)]
+		if rep.downUntil.Load() > now {
+			continue
+		}
+
+		err := fn(rep.db)
+		if err == nil || ctx.Err() != nil || !(isConnectionError(err) || errors.Is(err, ErrUnavailable)) {
+			return err
+		}
+		myCtx.GetLogger(ctx).Warn("read replica is unavailable, skipping it", "cooldown", replicaCooldown, "error", err)
+		rep.downUntil.Store(time.Now().Add(replicaCooldown).UnixNano())
+	}
+	return fn(r.primary)
+}
@@ -0,0 +1,78 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestReplicasReadQueryError(t *testing.T) {
+	primary, _, _ := sqlmock.New()
+	defer primary.Close()
+	replica, _, _ := sqlmock.New()
+	defer replica.Close()
+	replicas := NewReplicas(primary, replica)
+
+	// A query error would fail on the primary too, so it is returned without failing over.
+	var calls int
+	queryErr := errors.New(`pq: column "nope" does not exist`)
+	err := replicas.Read(context.Background(), func(q Querier) error {
+		calls++
+		return queryErr
+	})
+	if !errors.Is(err, queryErr) || calls != 1 {
+		t.Errorf("Read() = %v after %d calls, should return the query error after 1", err, calls)
+	}
+
+	// The replica is still used for the next read.
+	var got Querier
+	replicas.Read(context.Background(), func(q Querier) error {
+		got = q
+		return nil
+	})
+	if got != Querier(replica) {
+		t.Errorf("Read() used %v, should keep using the replica", got)
+	}
+}
+
+func TestReplicasRead(t *testing.T) {
+	primary, _, _ := sqlmock.New()
+	defer primary.Close()
+	replica1, _, _ := sqlmock.New()
+	defer replica1.Close()
+	replica2, _, _ := sqlmock.New()
+	defer replica2.Close()
+
+	replicas := NewReplicas(primary, replica1, replica2)
+	used := func(ctx context.Context, fail map[Querier]error) []Querier {
+		var got []Querier
+		replicas.Read(ctx, func(q Querier) error {
+			got = append(got, q)
+			return fail[q]
+		})
+		return got
+	}
+
+	// Reads are spread across the replicas.
+	first, second := used(context.Background(), nil), used(context.Background(), nil)
+	if len(first) != 1 || len(second) != 1 || first[0] == second[0] || first[0] == Querier(primary) {
+		t.Errorf("Read() used %v then %v, should use each replica in turn", first, second)
+	}
+
+	// Reads marked for the primary skip the replicas.
+	if got := used(WithPrimary(context.Background()), nil); len(got) != 1 || got[0] != Querier(primary) {
+		t.Errorf("Read() used %v, should use the primary", got)
+	}
+
+	// Replicas that can't be reached fall back to the primary, and are skipped afterwards.
+	down := map[Querier]error{replica1: sql.ErrConnDone, replica2: sql.ErrConnDone}
+	if got := used(context.Background(), down); len(got) != 3 || got[2] != Querier(primary) {
+		t.Errorf("Read() used %v, should try both replicas then the primary", got)
+	}
+	if got := used(context.Background(), nil); len(got) != 1 || got[0] != Querier(primary) {
+		t.Errorf("Read() used %v, should skip the replicas that are down", got)
+	}
+}
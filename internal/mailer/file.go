@@ -0,0 +1,35 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// Writes each email to its own .eml file in a directory instead of sending it.
+// Useful for local development and tests where no SMTP server is available.
+type FileMailer struct {
+	directory string
+	count     atomic.Uint64
+}
+
+// Create a new file sink Mailer. The directory is created if it does not exist.
+func NewFileMailer(directory string) *FileMailer {
+	return &FileMailer{
+		directory: directory,
+	}
+}
+
+func (m *FileMailer) Send(msg *Message) error {
+	if err := os.MkdirAll(m.directory, 0o755); err != nil {
+		return fmt.Errorf("unable to create mail directory: %v", err)
+	}
+
+	name := fmt.Sprintf("%d-%d.eml", time.Now().UnixNano(), m.count.Add(1))
+	if err := os.WriteFile(filepath.Join(m.directory, name), msg.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("unable to write email: %v", err)
+	}
+	return nil
+}
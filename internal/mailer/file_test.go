@@ -0,0 +1,34 @@
+package mailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	directory := filepath.Join(t.TempDir(), "mail")
+	m := NewFileMailer(directory)
+
+	for _, to := range []string{"bob@example.com", "ann@example.com"} {
+		if err := m.Send(&Message{From: "no-reply@localhost", To: to, Subject: "Hello", Body: "Line 1\nLine 2\n"}); err != nil {
+			t.Fatalf("Send() failed with %v", err)
+		}
+	}
+
+	// Each email gets its own file, even when sent at the same time.
+	files, err := filepath.Glob(filepath.Join(directory, "*.eml"))
+	if err != nil || len(files) != 2 {
+		t.Fatalf("found %d emails in %s, should be 2", len(files), directory)
+	}
+	content, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"From: no-reply@localhost\r\n", "Subject: Hello\r\n", "\r\n\r\nLine 1\r\nLine 2\r\n"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("email = %q, should contain %q", content, want)
+		}
+	}
+}
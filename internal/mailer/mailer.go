@@ -0,0 +1,35 @@
+// Sends outgoing email (ex: verification links) through a pluggable Mailer.
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Anything that can deliver an email Message.
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Body    string
+}
+
+// Renders the Message as a plain text RFC 5322 email, ready for SMTP or a file.
+func (m *Message) Bytes() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", m.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	buf.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+	return buf.Bytes()
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+)
+
+// Delivers email through an SMTP server (ex: a local Mailpit or MailHog container).
+type SMTPMailer struct {
+	addr string
+	auth smtp.Auth
+}
+
+// Create a new SMTP Mailer. Authentication is skipped when no username is provided.
+func NewSMTPMailer(host string, port string, username string, password string) *SMTPMailer {
+	m := &SMTPMailer{
+		addr: net.JoinHostPort(host, port),
+	}
+	if len(username) > 0 {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+func (m *SMTPMailer) Send(msg *Message) error {
+	if err := smtp.SendMail(m.addr, m.auth, msg.From, []string{msg.To}, msg.Bytes()); err != nil {
+		return fmt.Errorf("unable to send email: %v", err)
+	}
+	return nil
+}
@@ -1,45 +1,228 @@
 package migrator
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 
+	"github.com/agnate/qlikrestapi/migrations"
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
 type Migrator struct {
 	directoryName string
 	databaseName  string
+	driverName    string
+}
+
+// The migration state of a database.
+type Status struct {
+	Version uint   // Current version, zero if no migrations have been applied
+	Dirty   bool   // A migration failed part way through and must be fixed (see Force)
+	Latest  uint   // Newest version available
+	Pending []uint // Versions newer than the current one, oldest first
 }
 
 // Create a Migrator to allow us to migrate the schema(s) and updates.
-func New(dirName string, dbName string) *Migrator {
+//
+// # Parameters
+//   - dirName: Directory containing the migrations, or empty to use the ones embedded in the binary
+//   - dbName: Name of the database to migrate
+//   - driverName: Database driver, either "postgres" or "sqlite"
+func New(dirName string, dbName string, driverName string) *Migrator {
 	return &Migrator{
 		directoryName: dirName,
 		databaseName:  dbName,
+		driverName:    driverName,
 	}
 }
 
 // Run all schemas up to the latest version.
 func (m *Migrator) Run(db *sql.DB) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+	return m.Up(db, 0)
+}
+
+// Apply the next n migrations, or all of them if n is zero.
+func (m *Migrator) Up(db *sql.DB, n int) error {
+	migrator, err := m.open(db)
 	if err != nil {
-		return fmt.Errorf("unable to create db instance: %v", err)
+		return err
+	}
+
+	if n > 0 {
+		err = migrator.Steps(n)
+	} else {
+		err = migrator.Up()
+	}
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("unable to apply migrations %v", err)
+	}
+	return nil
+}
+
+// Roll back the last n migrations. Rolling back every migration deletes all data, so n
+// must be given explicitly.
+func (m *Migrator) Down(db *sql.DB, n int) error {
+	if n <= 0 {
+		return errors.New("number of migrations to roll back must be at least 1")
 	}
 
-	// TODO: Properly sanitize the directory name to add/omit slashes as needed.
-	migrator, err := migrate.NewWithDatabaseInstance("file://./migrations", m.databaseName, driver)
+	migrator, err := m.open(db)
 	if err != nil {
-		return fmt.Errorf("unable to create migration: %v", err)
+		return err
 	}
 
-	// Run migrations.
-	if err = migrator.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
-		return fmt.Errorf("unable to apply migrations %v", err)
+	if err = migrator.Steps(-n); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("unable to roll back migrations %v", err)
+	}
+	return nil
+}
+
+// Migrate up or down to a specific version.
+func (m *Migrator) Goto(db *sql.DB, version uint) error {
+	migrator, err := m.open(db)
+	if err != nil {
+		return err
 	}
 
+	if err = migrator.Migrate(version); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("unable to migrate to version %d %v", version, err)
+	}
 	return nil
 }
+
+// Set the version without running any migrations and clear the dirty flag. Used to recover
+// once a failed migration has been fixed by hand. A version of -1 means no migrations.
+func (m *Migrator) Force(db *sql.DB, version int) error {
+	migrator, err := m.open(db)
+	if err != nil {
+		return err
+	}
+
+	if err = migrator.Force(version); err != nil {
+		return fmt.Errorf("unable to force version %d %v", version, err)
+	}
+	return nil
+}
+
+// Get the current version of the database and the migrations still to be applied.
+func (m *Migrator) Status(db *sql.DB) (*Status, error) {
+	migrator, err := m.open(db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &Status{}
+	status.Version, status.Dirty, err = migrator.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("unable to read version %v", err)
+	}
+
+	// Find the migrations newer than the current version.
+	versions, err := m.versions()
+	if err != nil {
+		return nil, err
+	}
+	for _, version := range versions {
+		status.Latest = version
+		if version > status.Version {
+			status.Pending = append(status.Pending, version)
+		}
+	}
+	return status, nil
+}
+
+// Get the newest version available.
+func (m *Migrator) Latest() (uint, error) {
+	versions, err := m.versions()
+	if err != nil || len(versions) == 0 {
+		return 0, err
+	}
+	return versions[len(versions)-1], nil
+}
+
+// Get the current version of the database with a plain query. Unlike Status, this doesn't
+// set up the migration table or hold on to a connection, so it's cheap enough to run for
+// every health check. Returns an error if no migrations have been run yet.
+func (m *Migrator) Version(ctx context.Context, db *sql.DB) (version uint, dirty bool, err error) {
+	var raw int64
+	err = db.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&raw, &dirty)
+	if errors.Is(err, sql.ErrNoRows) || raw < 0 {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to read version %w", err)
+	}
+	return uint(raw), dirty, nil
+}
+
+// List the available migration versions, oldest first.
+func (m *Migrator) versions() ([]uint, error) {
+	src, err := m.newSource()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read migrations: %v", err)
+	}
+
+	var versions []uint
+	version, err := src.First()
+	for err == nil {
+		versions = append(versions, version)
+		version, err = src.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("unable to read migrations: %v", err)
+	}
+	return versions, nil
+}
+
+func (m *Migrator) open(db *sql.DB) (*migrate.Migrate, error) {
+	driver, err := m.newDriver(db)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create db instance: %v", err)
+	}
+
+	src, err := m.newSource()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read migrations: %v", err)
+	}
+
+	migrator, err := migrate.NewWithInstance("iofs", src, m.databaseName, driver)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create migration: %v", err)
+	}
+	return migrator, nil
+}
+
+// Read migrations from the configured directory, or from the ones embedded in the binary.
+// SQLite has its own migration set (in the sqlite subdirectory), since its SQL dialect
+// differs from Postgres.
+func (m *Migrator) newSource() (source.Driver, error) {
+	var fsys fs.FS = migrations.FS
+	if len(m.directoryName) > 0 {
+		fsys = os.DirFS(m.directoryName)
+	}
+
+	path := "."
+	if m.driverName == "sqlite" {
+		path = "sqlite"
+	}
+	return iofs.New(fsys, path)
+}
+
+func (m *Migrator) newDriver(db *sql.DB) (database.Driver, error) {
+	switch m.driverName {
+	case "postgres":
+		return postgres.WithInstance(db, &postgres.Config{})
+	case "sqlite":
+		return sqlite.WithInstance(db, &sqlite.Config{})
+	}
+	return nil, fmt.Errorf("unsupported database driver `%s`", m.driverName)
+}
@@ -0,0 +1,124 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func TestRunSQLite(t *testing.T) {
+	tests := []struct {
+		name    string
+		dirName string
+	}{
+		{"embedded", ""},
+		{"directory", "../../migrations"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dbName := filepath.Join(t.TempDir(), "test.db")
+			db, err := sql.Open("sqlite", "file:"+dbName)
+			if err != nil {
+				t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+			}
+			defer db.Close()
+
+			if err := New(test.dirName, dbName, "sqlite").Run(db); err != nil {
+				t.Fatalf("Run() = %v, should apply the migrations", err)
+			}
+
+			// The migrations add the message_revisions table.
+			if _, err := db.Exec("SELECT revision_date FROM message_revisions"); err != nil {
+				t.Errorf("an error '%s' was not expected when querying the migrated schema", err)
+			}
+		})
+	}
+}
+
+func TestUpDownGotoStatusSQLite(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", "file:"+dbName)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	defer db.Close()
+	m := New("", dbName, "sqlite")
+
+	checkStatus := func(wantVersion uint, wantPending int) {
+		t.Helper()
+		status, err := m.Status(db)
+		if err != nil {
+			t.Fatalf("Status() = %v, should read the version", err)
+		}
+		if status.Version != wantVersion || len(status.Pending) != wantPending || status.Dirty {
+			t.Errorf("Status() = %+v, want version %d with %d pending", status, wantVersion, wantPending)
+		}
+		if status.Latest != 5 {
+			t.Errorf("Status().Latest = %d, want 5", status.Latest)
+		}
+	}
+
+	checkStatus(0, 4)
+
+	if err := m.Up(db, 1); err != nil {
+		t.Fatalf("Up(1) = %v", err)
+	}
+	checkStatus(1, 3)
+
+	if err := m.Up(db, 0); err != nil {
+		t.Fatalf("Up(0) = %v", err)
+	}
+	checkStatus(5, 0)
+
+	if err := m.Down(db, 3); err != nil {
+		t.Fatalf("Down(3) = %v", err)
+	}
+	checkStatus(1, 3)
+	if _, err := db.Exec("SELECT verified FROM users"); err == nil {
+		t.Error("the verified column should be dropped after rolling back")
+	}
+
+	if err := m.Goto(db, 5); err != nil {
+		t.Fatalf("Goto(5) = %v", err)
+	}
+	checkStatus(5, 0)
+
+	if err := m.Down(db, 0); err == nil {
+		t.Error("Down(0) should be rejected")
+	}
+
+	if err := m.Force(db, 1); err != nil {
+		t.Fatalf("Force(1) = %v", err)
+	}
+	checkStatus(1, 3)
+}
+
+func TestVersionSQLite(t *testing.T) {
+	dbName := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", "file:"+dbName)
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when opening a sqlite database", err)
+	}
+	defer db.Close()
+	m := New("", dbName, "sqlite")
+
+	if _, _, err := m.Version(context.Background(), db); err == nil {
+		t.Error("Version() should fail before the migration table exists")
+	}
+
+	if err := m.Up(db, 1); err != nil {
+		t.Fatalf("Up(1) = %v", err)
+	}
+	version, dirty, err := m.Version(context.Background(), db)
+	if err != nil || version != 1 || dirty {
+		t.Errorf("Version() = %d, %t, %v, want 1, false, nil", version, dirty, err)
+	}
+
+	latest, err := m.Latest()
+	if err != nil || latest != 5 {
+		t.Errorf("Latest() = %d, %v, want 5", latest, err)
+	}
+}
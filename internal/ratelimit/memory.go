@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// How often buckets that have refilled are dropped from a Memory store.
+const sweepInterval = time.Minute
+
+// Store that keeps buckets in memory, so each API instance has its own limits.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // When the bucket will be full again, so it can be dropped
+}
+
+// Create a new, empty Memory store.
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, rate Rate) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Limit), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = min(float64(rate.Limit), b.tokens+now.Sub(b.updated).Seconds()*rate.perSecond())
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	result := newResult(rate, b.tokens, allowed)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+// Drop buckets that have refilled, since a new bucket would be the same.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+	for key, b := range m.buckets {
+		if !b.full.After(now) {
+			delete(m.buckets, key)
+		}
+	}
+}
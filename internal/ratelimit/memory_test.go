@@ -0,0 +1,70 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryTake(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	rate := Rate{Limit: 3, Period: 3 * time.Second}
+
+	for i := 2; i >= 0; i-- {
+		result, _ := m.Take(context.Background(), "a", rate)
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("Take() = %+v, want allowed with %d remaining", result, i)
+		}
+	}
+
+	result, _ := m.Take(context.Background(), "a", rate)
+	if result.Allowed || result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("Take() = %+v, want refused with 1s retry and 3s reset", result)
+	}
+	if result, _ := m.Take(context.Background(), "b", rate); !result.Allowed {
+		t.Errorf("Take() = %+v, other keys should have their own bucket", result)
+	}
+
+	// A token is added back every second.
+	now = now.Add(time.Second)
+	if result, _ := m.Take(context.Background(), "a", rate); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Take() = %+v, want allowed after refilling", result)
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	now := time.Now()
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	m.Take(context.Background(), "a", Rate{Limit: 10, Period: time.Second})
+
+	now = now.Add(sweepInterval)
+	m.Take(context.Background(), "b", Rate{Limit: 10, Period: time.Hour})
+	if _, ok := m.buckets["a"]; ok || len(m.buckets) != 1 {
+		t.Errorf("buckets = %v, refilled buckets should be dropped", m.buckets)
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Rate
+		wantErr bool
+	}{
+		{"60/1m", Rate{60, time.Minute}, false},
+		{"10/1h", Rate{10, time.Hour}, false},
+		{"0", Rate{}, false},
+		{"", Rate{}, false},
+		{"60", Rate{}, true},
+		{"x/1m", Rate{}, true},
+		{"60/0s", Rate{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseRate(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("ParseRate(%q) = %v, %v, want %v (error: %t)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
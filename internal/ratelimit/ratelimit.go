@@ -0,0 +1,85 @@
+// Token bucket rate limiting. Each key (ex: a User or client IP) has a bucket holding up to
+// Rate.Limit tokens, refilled evenly over Rate.Period. Every request takes a token, and is
+// refused when the bucket is empty.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How many requests are allowed per period. Requests can come in a burst of up to Limit,
+// after which they are allowed at a steady Limit/Period.
+type Rate struct {
+	Limit  int
+	Period time.Duration
+}
+
+// Parse a rate written as limit/period (ex: 60/1m). An empty string or "0" means no limit,
+// and is returned as the zero Rate.
+func ParseRate(s string) (Rate, error) {
+	if len(s) == 0 || s == "0" {
+		return Rate{}, nil
+	}
+	limit, period, found := strings.Cut(s, "/")
+	n, err := strconv.Atoi(limit)
+	if !found || err != nil || n < 0 {
+		return Rate{}, fmt.Errorf("rate `%s` must be written as limit/period (ex: 60/1m)", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("rate `%s` must have a positive period (ex: 60/1m)", s)
+	}
+	return Rate{Limit: n, Period: d}, nil
+}
+
+// Whether requests are limited at all.
+func (r Rate) Enabled() bool {
+	return r.Limit > 0 && r.Period > 0
+}
+
+// Tokens added back to a bucket per second.
+func (r Rate) perSecond() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+// Outcome of taking a token.
+type Result struct {
+	Allowed    bool
+	Limit      int           // Size of the bucket
+	Remaining  int           // Whole tokens left in the bucket
+	Reset      time.Duration // Until the bucket is full again
+	RetryAfter time.Duration // Until a token is available, zero when Allowed
+}
+
+// Build the Result for a bucket left holding tokens.
+func newResult(rate Rate, tokens float64, allowed bool) Result {
+	result := Result{
+		Allowed:   allowed,
+		Limit:     rate.Limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     secondsToDuration((float64(rate.Limit) - tokens) / rate.perSecond()),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - tokens) / rate.perSecond())
+	}
+	return result
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
+
+// Returned (wrapped) when the Store can't be reached.
+var ErrUnavailable = errors.New("rate limit store unavailable")
+
+// Keeps the buckets. Take removes a token from the bucket for key, refilling it first
+// for the time since it was last used.
+type Store interface {
+	Take(ctx context.Context, key string, rate Rate) (Result, error)
+}
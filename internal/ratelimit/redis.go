@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/agnate/qlikrestapi/internal/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+// Refills and takes a token from the bucket in a single step, so concurrent requests to
+// different API instances can't both take the last token. The server's clock is used so
+// instances with different clocks agree. Returns whether a token was taken and how many
+// are left (as a string, since Lua numbers are truncated to integers in replies).
+var takeScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(bucket[1]) or limit
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(limit, tokens + math.max(0, now - updated) * limit / period)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, tostring(tokens)}
+`)
+
+// Prefix added to bucket keys, so they can't clash with cached data.
+const redisKeyPrefix = "ratelimit:"
+
+// Store that keeps buckets in Redis, so limits are shared between API instances.
+type Redis struct {
+	redis *cache.Redis
+}
+
+// Create a new Redis store using an existing Redis connection pool (ex: the one used
+// for caching).
+func NewRedis(redis *cache.Redis) *Redis {
+	return &Redis{redis: redis}
+}
+
+func (s *Redis) Take(ctx context.Context, key string, rate Rate) (Result, error) {
+	reply, err := s.redis.Eval(ctx, takeScript, []string{redisKeyPrefix + key},
+		rate.Limit, max(rate.Period.Milliseconds(), 1))
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+
+	items, ok := reply.([]any)
+	if !ok || len(items) != 2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	allowed, ok := items[0].(int64)
+	tokensReply, ok2 := items[1].(string)
+	if !ok || !ok2 {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	tokens, err := strconv.ParseFloat(tokensReply, 64)
+	if err != nil {
+		return Result{}, fmt.Errorf("ratelimit: unexpected reply %v", reply)
+	}
+	return newResult(rate, tokens, allowed == 1), nil
+}
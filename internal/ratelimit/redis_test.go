@@ -0,0 +1,30 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/agnate/qlikrestapi/internal/cache"
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestRedisTake(t *testing.T) {
+	c := cache.NewRedis(miniredis.RunT(t).Addr(), "", 0, time.Second)
+	defer c.Close()
+	s := NewRedis(c)
+	rate := Rate{Limit: 3, Period: time.Hour}
+
+	for i := 2; i >= 0; i-- {
+		result, err := s.Take(context.Background(), "a", rate)
+		if err != nil || !result.Allowed || result.Remaining != i {
+			t.Fatalf("Take() = %+v, %v, want allowed with %d remaining", result, err, i)
+		}
+	}
+	if result, _ := s.Take(context.Background(), "a", rate); result.Allowed {
+		t.Errorf("Take() = %+v, want refused once the bucket is empty", result)
+	}
+	if result, _ := s.Take(context.Background(), "b", rate); !result.Allowed {
+		t.Errorf("Take() = %+v, other keys should have their own bucket", result)
+	}
+}
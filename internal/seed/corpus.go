@@ -0,0 +1,43 @@
+package seed
+
+// Messages are picked from these lists. Palindromes only ignore spaces and case (see
+// util.IsPalindrome), so none of them contain punctuation.
+var palindromes = []string{
+	"racecar",
+	"level",
+	"kayak",
+	"rotor",
+	"madam",
+	"taco cat",
+	"top spot",
+	"never odd or even",
+	"step on no pets",
+	"no lemon no melon",
+	"was it a car or a cat i saw",
+	"a man a plan a canal panama",
+}
+
+var nonPalindromes = []string{
+	"hello world",
+	"palindrome",
+	"sword",
+	"the quick brown fox",
+	"jumps over the lazy dog",
+	"lorem ipsum dolor sit amet",
+	"seeded message",
+	"almost a palindrome",
+	"not quite racecar",
+	"testing one two three",
+}
+
+// Names given to seeded Users, numbered once every name has been used.
+var names = []string{
+	"Ada Lovelace",
+	"Alan Turing",
+	"Grace Hopper",
+	"Edsger Dijkstra",
+	"Barbara Liskov",
+	"Donald Knuth",
+	"Margaret Hamilton",
+	"Ken Thompson",
+}
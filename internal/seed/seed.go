@@ -0,0 +1,166 @@
+// Fills a Store with demo Users and Messages. The data only depends on the seed, so
+// integration tests can rely on it, and running it again only adds what is missing.
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/agnate/qlikrestapi/api/entity/message"
+	"github.com/agnate/qlikrestapi/api/entity/user"
+	"github.com/agnate/qlikrestapi/api/store"
+	"github.com/agnate/qlikrestapi/internal/apikey"
+	"github.com/agnate/qlikrestapi/internal/util"
+	"github.com/google/uuid"
+)
+
+type Options struct {
+	Users    int   // Number of Users to create
+	Messages int   // Number of Messages to create, shared between the Users
+	Seed     int64 // Seed for picking API keys and messages
+}
+
+// A seeded User. RawAPIKey is only set when the User was created by this run, since only
+// the hash is stored.
+type SeededUser struct {
+	User      *user.User
+	RawAPIKey string
+	Created   bool
+}
+
+type Result struct {
+	Users           []SeededUser
+	MessagesCreated int
+}
+
+// Create the seeded Users and Messages that don't exist yet, all in a single transaction.
+//
+// Users are matched by email (seed-user-N@example.com), and each User's Messages by text,
+// so running it again with the same options doesn't add anything (and with more Messages
+// only adds the extra ones). API keys are generated from the seed, so a fresh database
+// always gets the same keys.
+func Run(ctx context.Context, s *store.Store, opts Options) (*Result, error) {
+	if opts.Users < 0 || opts.Messages < 0 {
+		return nil, errors.New("number of users and messages must not be negative")
+	}
+	if opts.Messages > 0 && opts.Users == 0 {
+		return nil, errors.New("at least one user is needed to create messages")
+	}
+
+	result := &Result{}
+	err := s.WithTx(ctx, func(tx *store.Store) error {
+		// Always draw the same values from the generator, whether or not they are used,
+		// so the data doesn't depend on what already exists.
+		rng := rand.New(rand.NewSource(opts.Seed))
+
+		// Create the Users.
+		existing, err := tx.Users.List(ctx)
+		if err != nil {
+			return err
+		}
+		byEmail := make(map[string]*user.User, len(existing))
+		for _, u := range existing {
+			byEmail[u.Email] = u
+		}
+		for i := 0; i < opts.Users; i++ {
+			seeded, err := seedUser(ctx, tx, rng, i, byEmail)
+			if err != nil {
+				return err
+			}
+			result.Users = append(result.Users, seeded)
+		}
+
+		// Pick the text of every Message up front, then create the ones each User is missing.
+		texts := make([][]string, opts.Users)
+		for i := 0; i < opts.Messages; i++ {
+			texts[i%opts.Users] = append(texts[i%opts.Users], pickMessage(rng))
+		}
+		for i, seeded := range result.Users {
+			created, err := seedMessages(ctx, tx, seeded.User, texts[i])
+			if err != nil {
+				return err
+			}
+			result.MessagesCreated += created
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Create the i-th seeded User, unless they already exist.
+func seedUser(ctx context.Context, tx *store.Store, rng *rand.Rand, i int, byEmail map[string]*user.User) (SeededUser, error) {
+	key, err := uuid.NewRandomFromReader(rng)
+	if err != nil {
+		return SeededUser{}, err
+	}
+	rawAPIKey := key.String()
+
+	email := fmt.Sprintf("seed-user-%d@example.com", i+1)
+	if u, ok := byEmail[email]; ok {
+		return SeededUser{User: u}, nil
+	}
+
+	name := names[i%len(names)]
+	if i >= len(names) {
+		name = fmt.Sprintf("%s %d", name, i/len(names)+1)
+	}
+	newUser, err := tx.Users.Create(ctx, &user.User{
+		Name:     name,
+		Email:    email,
+		APIKey:   apikey.HashByteToString(apikey.HashAPIKey(rawAPIKey)),
+		Verified: true, // Seeded Users can always post
+	})
+	if err != nil {
+		return SeededUser{}, fmt.Errorf("unable to create %s: %w", email, err)
+	}
+	return SeededUser{User: newUser, RawAPIKey: rawAPIKey, Created: true}, nil
+}
+
+// Create the Messages in texts that the User doesn't have yet. Returns how many were created.
+func seedMessages(ctx context.Context, tx *store.Store, author *user.User, texts []string) (int, error) {
+	msgs, err := tx.Messages.ListByUUID(ctx, author.UUID)
+	if err != nil {
+		return 0, err
+	}
+	have := make(map[string]int, len(msgs))
+	for _, msg := range msgs {
+		have[msg.Message]++
+	}
+
+	created := 0
+	for _, text := range texts {
+		if have[text] > 0 {
+			have[text]--
+			continue
+		}
+		_, err := tx.Messages.Create(ctx, &message.Message{
+			UUID:          author.UUID,
+			Message:       text,
+			Palindrome:    util.IsPalindrome(text),
+			LastUpdatedBy: author.UUID,
+		})
+		if err != nil {
+			return created, fmt.Errorf("unable to create message for %s: %w", author.Email, err)
+		}
+		created++
+
+		// CreateDate is part of the key and only has microsecond precision, so make sure
+		// the next Message gets a different one.
+		time.Sleep(time.Microsecond)
+	}
+	return created, nil
+}
+
+// Pick a message from the corpus, roughly half of them palindromes.
+func pickMessage(rng *rand.Rand) string {
+	if rng.Intn(2) == 0 {
+		return palindromes[rng.Intn(len(palindromes))]
+	}
+	return nonPalindromes[rng.Intn(len(nonPalindromes))]
+}
@@ -0,0 +1,112 @@
+package seed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/agnate/qlikrestapi/api/store"
+)
+
+func TestRunIsDeterministic(t *testing.T) {
+	opts := Options{Users: 3, Messages: 10, Seed: 42}
+	first, err := Run(context.Background(), store.NewMemory(), opts)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	second, err := Run(context.Background(), store.NewMemory(), opts)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	for i := range first.Users {
+		if first.Users[i].RawAPIKey == "" || first.Users[i].RawAPIKey != second.Users[i].RawAPIKey {
+			t.Errorf("user %d API key = %q and %q, should be the same for the same seed", i, first.Users[i].RawAPIKey, second.Users[i].RawAPIKey)
+		}
+	}
+
+	other, err := Run(context.Background(), store.NewMemory(), Options{Users: 1, Seed: 7})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if other.Users[0].RawAPIKey == first.Users[0].RawAPIKey {
+		t.Error("a different seed should give different API keys")
+	}
+}
+
+func TestRunIsIdempotent(t *testing.T) {
+	s := store.NewMemory()
+	opts := Options{Users: 3, Messages: 10, Seed: 42}
+
+	first, err := Run(context.Background(), s, opts)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if first.MessagesCreated != 10 {
+		t.Errorf("first run created %d messages, want 10", first.MessagesCreated)
+	}
+
+	second, err := Run(context.Background(), s, opts)
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if second.MessagesCreated != 0 {
+		t.Errorf("second run created %d messages, want 0", second.MessagesCreated)
+	}
+	for i, seeded := range second.Users {
+		if seeded.Created || seeded.RawAPIKey != "" {
+			t.Errorf("user %d should already exist and not have their API key printed again", i)
+		}
+		if seeded.User.UUID != first.Users[i].User.UUID {
+			t.Errorf("user %d = %s, want the existing %s", i, seeded.User.UUID, first.Users[i].User.UUID)
+		}
+	}
+
+	users, _ := s.Users.List(context.Background())
+	msgs, _ := s.Messages.List(context.Background())
+	if len(users) != 3 || len(msgs) != 10 {
+		t.Errorf("store has %d users and %d messages, want 3 and 10", len(users), len(msgs))
+	}
+
+	// Asking for more messages only adds the difference.
+	third, err := Run(context.Background(), s, Options{Users: 3, Messages: 20, Seed: 42})
+	if err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+	if third.MessagesCreated != 10 {
+		t.Errorf("third run created %d messages, want 10", third.MessagesCreated)
+	}
+	msgs, _ = s.Messages.List(context.Background())
+	if len(msgs) != 20 {
+		t.Errorf("store has %d messages, want 20", len(msgs))
+	}
+}
+
+func TestRunMixesPalindromes(t *testing.T) {
+	s := store.NewMemory()
+	if _, err := Run(context.Background(), s, Options{Users: 2, Messages: 40, Seed: 1}); err != nil {
+		t.Fatalf("Run() = %v", err)
+	}
+
+	msgs, _ := s.Messages.List(context.Background())
+	palindromes := 0
+	for _, msg := range msgs {
+		if msg.Palindrome {
+			palindromes++
+		}
+	}
+	if palindromes == 0 || palindromes == len(msgs) {
+		t.Errorf("%d of %d messages are palindromes, want a mix", palindromes, len(msgs))
+	}
+}
+
+func TestRunInvalidOptions(t *testing.T) {
+	tests := []Options{
+		{Users: -1},
+		{Users: 0, Messages: 5},
+	}
+	for _, opts := range tests {
+		if _, err := Run(context.Background(), store.NewMemory(), opts); err == nil {
+			t.Errorf("Run(%+v) should fail", opts)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Creates and verifies signed, expiring tokens that can be handed to users (ex: email
+// verification links) without storing the raw value in the database.
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalid = errors.New("invalid token")
+	ErrExpired = errors.New("token has expired")
+)
+
+// Creates a token for the subject (ex: a User UUID) that expires at the given time.
+// A random nonce is included so that every token is unique, even for the same subject.
+func Sign(secret []byte, subject string, expires time.Time) (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("unable to generate token nonce: %v", err)
+	}
+
+	payload := strings.Join([]string{subject, strconv.FormatInt(expires.Unix(), 10), hex.EncodeToString(nonce)}, "|")
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + sign(secret, encoded), nil
+}
+
+// Checks the token signature and expiry, and returns the subject it was created for.
+func Verify(secret []byte, token string, now time.Time) (string, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(sign(secret, encoded))) {
+		return "", ErrInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", ErrInvalid
+	}
+
+	parts := strings.Split(string(payload), "|")
+	if len(parts) != 3 {
+		return "", ErrInvalid
+	}
+
+	expires, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalid
+	}
+	if now.After(time.Unix(expires, 0)) {
+		return "", ErrExpired
+	}
+
+	return parts[0], nil
+}
+
+// Hashes a token for storage, so a single-use token can be matched (and then
+// cleared) without keeping the raw value.
+func Hash(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
+
+func sign(secret []byte, encoded string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
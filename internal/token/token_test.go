@@ -0,0 +1,44 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var secret = []byte("test-secret")
+
+func TestTokenRoundTrip(t *testing.T) {
+	subject := "fd06d3e1-c405-4ff3-945c-34b98ef49e8c"
+	tok, err := Sign(secret, subject, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("an error '%s' was not expected when signing a token", err)
+	}
+
+	got, err := Verify(secret, tok, time.Now())
+	if err != nil || got != subject {
+		t.Fatalf("Verify() = %q, %v, should be %q, nil", got, err, subject)
+	}
+}
+
+func TestTokenExpired(t *testing.T) {
+	tok, _ := Sign(secret, "subject", time.Now().Add(time.Hour))
+	if _, err := Verify(secret, tok, time.Now().Add(2*time.Hour)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("Verify() returned %v, should be %v", err, ErrExpired)
+	}
+}
+
+func TestTokenWrongSecret(t *testing.T) {
+	tok, _ := Sign(secret, "subject", time.Now().Add(time.Hour))
+	if _, err := Verify([]byte("other-secret"), tok, time.Now()); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Verify() returned %v, should be %v", err, ErrInvalid)
+	}
+}
+
+func TestTokenTampered(t *testing.T) {
+	tok, _ := Sign(secret, "subject", time.Now().Add(time.Hour))
+	tampered := "x" + tok[1:]
+	if _, err := Verify(secret, tampered, time.Now()); !errors.Is(err, ErrInvalid) {
+		t.Fatalf("Verify() returned %v, should be %v", err, ErrInvalid)
+	}
+}
@@ -0,0 +1,106 @@
+// OpenTelemetry tracing: spans for each request, the work it does (ex: API key lookups)
+// and the queries it runs, exported with OTLP or written to stdout.
+//
+// Spans are started with Start, which uses the global tracer provider. Until Setup is
+// called (or when tracing is turned off) spans are no-ops, so they cost next to nothing.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name of the instrumentation, shown on every span.
+const instrumentationName = "github.com/agnate/qlikrestapi"
+
+// How long Close waits for buffered spans to be exported.
+const closeTimeout = 5 * time.Second
+
+// Tracing settings.
+type Config struct {
+	Exporter     string // "none", "stdout" or "otlp"
+	OTLPEndpoint string // OTLP/HTTP URL (ex: http://localhost:4318/v1/traces), or empty for the OTEL_EXPORTER_OTLP_* defaults
+	ServiceName  string
+	Version      string
+}
+
+// Exports the spans started with Start. Close flushes anything not yet exported.
+type Provider struct {
+	provider *sdktrace.TracerProvider
+}
+
+// Set up the global tracer provider and the W3C trace context propagator for c. Returns
+// nil (and no error) when tracing is turned off, in which case spans are no-ops. The trace
+// context is still propagated, so traces started upstream aren't broken by this service.
+func Setup(c Config) (*Provider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch c.Exporter {
+	case "none", "":
+		return nil, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if len(c.OTLPEndpoint) > 0 {
+			opts = append(opts, otlptracehttp.WithEndpointURL(c.OTLPEndpoint))
+		}
+		exporter, err = otlptracehttp.New(context.Background(), opts...)
+	default:
+		return nil, fmt.Errorf("unsupported trace exporter `%s`", c.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewSchemaless(semconv.ServiceName(c.ServiceName), semconv.ServiceVersion(c.Version))
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		// Follow the caller's sampling decision, so a trace is either kept or dropped as a whole.
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	)
+	otel.SetTracerProvider(provider)
+	return &Provider{provider: provider}, nil
+}
+
+// Export any buffered spans and stop the provider.
+func (p *Provider) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return p.provider.Shutdown(ctx)
+}
+
+// Start a span as a child of the span in ctx (if any).
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End span, marking it as failed if err isn't nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// Get the trace context sent by the client (ex: in the traceparent header), so the spans
+// for a request join the caller's trace.
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
@@ -2,15 +2,16 @@ package baddata
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
 	"github.com/agnate/qlikrestapi/internal/util"
 )
 
 type BadData struct {
-	ErrorMsg string
-	err      error
+	ErrorMsg  string
+	RequestID string `json:",omitempty"` // Lets users quote the request when reporting a problem
+	err       error
 }
 
 // Create a BadData entry to be displayed to the user in the API body.
@@ -22,10 +23,11 @@ func New400BadData(err error) *BadData {
 }
 
 // Used when validation or data saving fails for an endpoint and we want a consistent
-// output displayed to our users. Errors will be logged.
-func (bd *BadData) Render(w http.ResponseWriter) {
-	// TODO: Add logging for invalid endpoints in case we need to monitor spammers.
-	log.Println(bd.err)
+// output displayed to our users. Errors will be logged, and the response counted by the
+// suspicious traffic monitor (see middleware.Monitor).
+func (bd *BadData) Render(w http.ResponseWriter, r *http.Request) {
+	myCtx.GetLogger(r.Context()).InfoContext(r.Context(), "bad request data", "error", bd.err)
+	bd.RequestID = util.RequestID(r)
 	http.Error(w, util.NewHttpStatusMsg(http.StatusBadRequest), http.StatusBadRequest)
 	if err := json.NewEncoder(w).Encode(bd); err != nil {
 		return
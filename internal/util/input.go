@@ -0,0 +1,84 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Decode the JSON request body into a new T. The body must hold a single JSON object with
+// only the fields T has, and be within the size limit (see middleware.MaxBodySize). Errors
+// say what is wrong and where (ex: "message must be a string, got number"), so they can
+// be shown to users as they are.
+func DecodeJSONBody[T any](r *http.Request) (*T, error) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var v T
+	if err := decoder.Decode(&v); err != nil {
+		return nil, jsonBodyError(err)
+	}
+	// Anything after the first value (other than whitespace) is rejected.
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return nil, jsonBodyError(err)
+		}
+		return nil, errors.New("request body must contain a single JSON object")
+	}
+	return &v, nil
+}
+
+// Describe a JSON decoding error in terms of the request body.
+func jsonBodyError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return fmt.Errorf("request body must not be larger than %d bytes: %w", maxBytesErr.Limit, err)
+	case errors.Is(err, io.EOF):
+		return errors.New("request body must not be empty")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("request body contains badly-formed JSON")
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("request body contains badly-formed JSON (at character %d)", syntaxErr.Offset)
+	case errors.As(err, &typeErr):
+		if len(typeErr.Field) == 0 {
+			return fmt.Errorf("request body must be a JSON object, got %s", typeErr.Value)
+		}
+		return fmt.Errorf("%s must be %s, got %s", typeErr.Field, describeType(typeErr.Type), typeErr.Value)
+	case errors.As(err, &timeErr):
+		return fmt.Errorf("invalid date %q, dates must be in RFC 3339 format (ex: 2006-01-02T15:04:05Z)", timeErr.Value)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return fmt.Errorf("request body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+	}
+	return err
+}
+
+// Describe the JSON value expected for a Go type (ex: "a string").
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Struct, reflect.Map:
+		return "an object"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	}
+	return "a " + t.String()
+}
@@ -0,0 +1,66 @@
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testInput struct {
+	Name    string    `json:"full_name"`
+	Age     int       `json:"age"`
+	Date    time.Time `json:"date"`
+	Address struct {
+		City string `json:"city"`
+	} `json:"address"`
+}
+
+func TestDecodeJSONBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"valid", `{"full_name": "Ann", "age": 30, "address": {"city": "Ottawa"}}`, ""},
+		{"trailing whitespace", "{\"full_name\": \"Ann\"}\n", ""},
+		{"empty", "", "request body must not be empty"},
+		{"unknown field", `{"full_name": "Ann", "nickname": "A"}`, `request body contains unknown field "nickname"`},
+		{"multiple values", `{"full_name": "Ann"}{"full_name": "Bob"}`, "request body must contain a single JSON object"},
+		{"trailing garbage", `{"full_name": "Ann"} garbage`, "request body must contain a single JSON object"},
+		{"wrong type", `{"full_name": 42}`, "full_name must be a string, got number"},
+		{"wrong nested type", `{"address": {"city": true}}`, "address.city must be a string, got bool"},
+		{"not an object", `["Ann"]`, "request body must be a JSON object, got array"},
+		{"bad syntax", `{"full_name": "Ann",}`, "request body contains badly-formed JSON (at character 21)"},
+		{"truncated", `{"full_name": "Ann"`, "request body contains badly-formed JSON"},
+		{"bad date", `{"date": "yesterday"}`, `invalid date "yesterday", dates must be in RFC 3339 format`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tt.body))
+			input, err := DecodeJSONBody[testInput](r)
+			if len(tt.wantErr) == 0 {
+				if err != nil || input == nil {
+					t.Fatalf("DecodeJSONBody() failed with %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("DecodeJSONBody() error = %v, should start with %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONBodyTooLarge(t *testing.T) {
+	body := `{"full_name": "` + strings.Repeat("a", 100) + `"}`
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	r.Body = http.MaxBytesReader(w, r.Body, 50)
+
+	_, err := DecodeJSONBody[testInput](r)
+	if !StatusBodyTooLarge(w, r, err) || w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("DecodeJSONBody() error = %v with status %d, should be a 413", err, w.Code)
+	}
+}
@@ -1,9 +1,13 @@
 package util
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"strconv"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/database"
 )
 
 // statusCode: Use constants from http package (ex: [net/http.StatusMethodNotAllowed])
@@ -11,28 +15,104 @@ func NewHttpStatusMsg(statusCode int) string {
 	return strconv.Itoa(statusCode) + " " + http.StatusText(statusCode)
 }
 
+// 401 Unauthorized - Used when a request is missing valid credentials (ex: API key).
+// Errors will be logged.
+func Status401Unauthorized(w http.ResponseWriter, r *http.Request, err error) {
+	httpError(w, r, http.StatusUnauthorized, err)
+}
+
+// 403 Forbidden - Used when a client isn't allowed to make the request (ex: it has been
+// blocked). Errors will be logged.
+func Status403Forbidden(w http.ResponseWriter, r *http.Request, err error) {
+	httpError(w, r, http.StatusForbidden, err)
+}
+
 // 404 Not Found - Used when validation or data loading fails for an endpoint
 // and we want a consistent output displayed to our users. Errors will be logged.
 func Status404NoAPIEndpoint(w http.ResponseWriter, r *http.Request, err error) {
-	// TODO: Add logging for invalid endpoints in case we need to monitor spammers.
-	log.Println(err)
-	http.NotFound(w, r)
+	httpError(w, r, http.StatusNotFound, err)
 }
 
 // 405 Not Allowed - Used when an unavailable request METHOD is supplied for a route.
 // Errors will be logged.
-func Status405APINotAllowed(w http.ResponseWriter, err error) {
-	// TODO: Add logging for invalid endpoints in case we need to monitor spammers.
-	log.Println(err)
-	http.Error(w, NewHttpStatusMsg(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+func Status405APINotAllowed(w http.ResponseWriter, r *http.Request, err error) {
+	httpError(w, r, http.StatusMethodNotAllowed, err)
+}
+
+// 413 Request Entity Too Large - Used when the request body is over the size limit.
+// Errors will be logged.
+func Status413RequestTooLarge(w http.ResponseWriter, r *http.Request, err error) {
+	httpError(w, r, http.StatusRequestEntityTooLarge, err)
+}
+
+// 429 Too Many Requests - Used when a client has gone over its rate limit. Errors will be logged.
+func Status429TooManyRequests(w http.ResponseWriter, r *http.Request, err error) {
+	httpError(w, r, http.StatusTooManyRequests, err)
 }
 
 // 500 Internal Server Error - Used when an unexpected error occurs and we want a consistent
 // output displayed to our users. Errors will be logged.
-func Status500APIError(w http.ResponseWriter, err error) {
-	// TODO: Add logging for invalid endpoints in case we need to monitor spammers.
-	log.Println(err)
-	http.Error(w, NewHttpStatusMsg(http.StatusInternalServerError), http.StatusInternalServerError)
+func Status500APIError(w http.ResponseWriter, r *http.Request, err error) {
+	httpError(w, r, http.StatusInternalServerError, err)
+}
+
+// 503 Service Unavailable - Used when the database can't be reached. Errors will be logged.
+func Status503Unavailable(w http.ResponseWriter, r *http.Request, err error) {
+	httpError(w, r, http.StatusServiceUnavailable, err)
+}
+
+// 504 Gateway Timeout - Used when a database query takes too long. Errors will be logged.
+func Status504Timeout(w http.ResponseWriter, r *http.Request, err error) {
+	httpError(w, r, http.StatusGatewayTimeout, err)
+}
+
+// Respond with a 503 or 504 if err was caused by the database being unavailable or too slow.
+// Returns false without writing anything for any other error, so the caller can respond as usual.
+func StatusDatabaseError(w http.ResponseWriter, r *http.Request, err error) bool {
+	switch {
+	case errors.Is(err, database.ErrTimeout):
+		Status504Timeout(w, r, err)
+	case errors.Is(err, database.ErrUnavailable):
+		Status503Unavailable(w, r, err)
+	default:
+		return false
+	}
+	return true
+}
+
+// Respond with a 413 if err was caused by the request body being over the size limit.
+// Returns false without writing anything for any other error, so the caller can respond as usual.
+func StatusBodyTooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(err, &maxBytesErr) {
+		return false
+	}
+	Status413RequestTooLarge(w, r, err)
+	return true
+}
+
+// Get the ID of the request being responded to, which the request ID middleware puts in
+// the request context. Returns an empty string if there isn't one.
+func RequestID(r *http.Request) string {
+	return myCtx.GetRequestID(r.Context())
+}
+
+// Log err with the request's logger and write a plain text error response. The request ID
+// is included in the body so users can quote it when reporting a problem. 4xx responses are
+// also counted per client by the suspicious traffic monitor (see middleware.Monitor). Server
+// errors are logged as errors, the rest (ex: a missing API key) as warnings.
+func httpError(w http.ResponseWriter, r *http.Request, statusCode int, err error) {
+	level := slog.LevelWarn
+	if statusCode >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	myCtx.GetLogger(r.Context()).Log(r.Context(), level, "request failed", "status", statusCode, "error", err)
+
+	msg := NewHttpStatusMsg(statusCode)
+	if id := RequestID(r); len(id) > 0 {
+		msg += "\nRequest ID: " + id
+	}
+	http.Error(w, msg, statusCode)
 }
 
 // Writes out content-type header for JSON.
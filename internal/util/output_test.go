@@ -0,0 +1,60 @@
+package util
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	myCtx "github.com/agnate/qlikrestapi/internal/context"
+	"github.com/agnate/qlikrestapi/internal/database"
+)
+
+func TestStatusDatabaseError(t *testing.T) {
+	tests := []struct {
+		err        error
+		handled    bool
+		wantStatus int
+	}{
+		{database.CheckError(context.Background(), context.DeadlineExceeded), true, http.StatusGatewayTimeout},
+		{database.CheckError(context.Background(), context.Canceled), true, http.StatusServiceUnavailable},
+		{fmt.Errorf("query failed: %w", database.ErrUnavailable), true, http.StatusServiceUnavailable},
+		{errors.New("no rows updated"), false, http.StatusOK},
+		{nil, false, http.StatusOK},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handled := StatusDatabaseError(w, httptest.NewRequest(http.MethodGet, "/", nil), test.err)
+		if handled != test.handled || w.Code != test.wantStatus {
+			t.Errorf("StatusDatabaseError(%v) = %t with status %d, should be %t with status %d", test.err, handled, w.Code, test.handled, test.wantStatus)
+		}
+	}
+}
+
+func TestErrorIncludesRequestID(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(myCtx.SetRequestID(r.Context(), "abc-123"))
+	Status500APIError(w, r, errors.New("boom"))
+
+	if !strings.Contains(w.Body.String(), "abc-123") {
+		t.Errorf("body = %q, should include the request ID", w.Body.String())
+	}
+}
+
+func TestErrorUsesRequestLogger(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil)).With("user", "b16fc69c")
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r = r.WithContext(myCtx.SetLogger(r.Context(), logger))
+	Status500APIError(httptest.NewRecorder(), r, errors.New("boom"))
+
+	if !strings.Contains(logs.String(), "user=b16fc69c") || !strings.Contains(logs.String(), "error=boom") {
+		t.Errorf("logs = %q, should be written with the request's logger", logs.String())
+	}
+}
@@ -0,0 +1,49 @@
+package validation
+
+import (
+	"errors"
+	"net/mail"
+	"strings"
+)
+
+// Maximum lengths for an address as defined by RFC 5321 (section 4.5.3.1).
+const (
+	emailMaxLength      = 254
+	emailLocalMaxLength = 64
+)
+
+type RuleEmail struct {
+	Raw        string
+	Normalized string
+}
+
+// Validates an email address against the RFC 5322 addr-spec syntax and normalizes
+// it (surrounding whitespace removed, lowercased) for storage and comparison.
+func NewRuleEmail(email string) (*RuleEmail, error) {
+	v := &RuleEmail{
+		Raw: email,
+	}
+	return v, v.validate()
+}
+
+func (v *RuleEmail) validate() error {
+	trimmed := strings.TrimSpace(v.Raw)
+	if len(trimmed) <= 0 {
+		return errors.New("you must provide a valid email")
+	}
+
+	// Only a bare address is accepted (ex: "bob@example.com"), so reject display
+	// names, angle brackets and comments even though the parser understands them.
+	addr, err := mail.ParseAddress(trimmed)
+	if err != nil || addr.Name != "" || strings.ContainsAny(trimmed, "<>()") {
+		return errors.New("you must provide a valid email")
+	}
+
+	at := strings.LastIndex(trimmed, "@")
+	if len(trimmed) > emailMaxLength || at > emailLocalMaxLength {
+		return errors.New("email is too long")
+	}
+
+	v.Normalized = strings.ToLower(trimmed)
+	return nil
+}
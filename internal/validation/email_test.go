@@ -0,0 +1,64 @@
+package validation
+
+import (
+	"testing"
+)
+
+func TestRuleEmailValid(t *testing.T) {
+	test := "bob@example.com"
+	v, err := NewRuleEmail(test)
+	if err != nil || v.Normalized != test {
+		t.Fatalf(`NewRuleEmail("%s") = %q, %v, should be %q, nil`, test, v.Normalized, err, test)
+	}
+}
+
+func TestRuleEmailNormalized(t *testing.T) {
+	test := "  Bob.Ross@Example.COM \n"
+	want := "bob.ross@example.com"
+	v, err := NewRuleEmail(test)
+	if err != nil || v.Normalized != want {
+		t.Fatalf(`NewRuleEmail("%s") = %q, %v, should be %q, nil`, test, v.Normalized, err, want)
+	}
+}
+
+func TestRuleEmailQuotedLocalPart(t *testing.T) {
+	test := `"bob ross"@example.com`
+	if _, err := NewRuleEmail(test); err != nil {
+		t.Fatalf(`NewRuleEmail("%s") returned error %v, should be nil`, test, err)
+	}
+}
+
+func TestRuleEmailEmpty(t *testing.T) {
+	test := "   "
+	if _, err := NewRuleEmail(test); err == nil {
+		t.Fatalf(`NewRuleEmail("%s") returned nil, should be an error`, test)
+	}
+}
+
+func TestRuleEmailMissingAt(t *testing.T) {
+	test := "bob.example.com"
+	if _, err := NewRuleEmail(test); err == nil {
+		t.Fatalf(`NewRuleEmail("%s") returned nil, should be an error`, test)
+	}
+}
+
+func TestRuleEmailDoubleDot(t *testing.T) {
+	test := "bob..ross@example.com"
+	if _, err := NewRuleEmail(test); err == nil {
+		t.Fatalf(`NewRuleEmail("%s") returned nil, should be an error`, test)
+	}
+}
+
+func TestRuleEmailDisplayName(t *testing.T) {
+	test := "Bob <bob@example.com>"
+	if _, err := NewRuleEmail(test); err == nil {
+		t.Fatalf(`NewRuleEmail("%s") returned nil, should be an error`, test)
+	}
+}
+
+func TestRuleEmailLocalPartTooLong(t *testing.T) {
+	test := "abcdefghijklmnopqrstuvwxyzabcdefghijklmnopqrstuvwxyzabcdefghijklm@example.com" // 65 characters
+	if _, err := NewRuleEmail(test); err == nil {
+		t.Fatalf(`NewRuleEmail("%s") returned nil, should be an error`, test)
+	}
+}
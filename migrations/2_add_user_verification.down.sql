@@ -0,0 +1,3 @@
+ALTER TABLE IF EXISTS users
+    DROP COLUMN IF EXISTS verification_token,
+    DROP COLUMN IF EXISTS verified;
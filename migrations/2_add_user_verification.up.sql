@@ -0,0 +1,6 @@
+ALTER TABLE IF EXISTS users
+    ADD COLUMN IF NOT EXISTS verified boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS verification_token text COLLATE pg_catalog."default" NOT NULL DEFAULT '';
+
+-- Users created before verification existed are treated as verified so they can keep posting.
+UPDATE users SET verified = true;
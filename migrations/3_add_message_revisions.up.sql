@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS message_revisions
+(
+    uuid uuid NOT NULL,
+    create_date timestamp without time zone NOT NULL,
+    revision_date timestamp without time zone NOT NULL,
+    message text COLLATE pg_catalog."default",
+    is_palindrome boolean,
+    last_updated_by uuid,
+    CONSTRAINT message_revisions_pkey PRIMARY KEY (uuid, create_date, revision_date),
+    CONSTRAINT message FOREIGN KEY (uuid, create_date)
+        REFERENCES messages (uuid, create_date) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT last_updated_by FOREIGN KEY (last_updated_by)
+        REFERENCES users (uuid) MATCH SIMPLE
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION
+)
+
+TABLESPACE pg_default;
+
+ALTER TABLE IF EXISTS message_revisions
+    OWNER to postgres;
@@ -0,0 +1,17 @@
+ALTER TABLE IF EXISTS messages
+    ALTER COLUMN create_date SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN last_updated SET DEFAULT CURRENT_TIMESTAMP;
+
+ALTER TABLE IF EXISTS users
+    ALTER COLUMN last_access SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN create_date SET DEFAULT CURRENT_TIMESTAMP;
+
+UPDATE message_revisions SET
+    revision_date = revision_date AT TIME ZONE 'UTC' AT TIME ZONE current_setting('TimeZone');
+
+UPDATE messages SET
+    last_updated = last_updated AT TIME ZONE 'UTC' AT TIME ZONE current_setting('TimeZone');
+
+UPDATE users SET
+    last_access = last_access AT TIME ZONE 'UTC' AT TIME ZONE current_setting('TimeZone'),
+    create_date = create_date AT TIME ZONE 'UTC' AT TIME ZONE current_setting('TimeZone');
@@ -0,0 +1,26 @@
+-- Timestamps used to come from CURRENT_TIMESTAMP, which "timestamp without time zone" columns
+-- store in the server's time zone, but the API now writes them in UTC. Convert the existing
+-- rows (assuming the server's time zone hasn't changed since they were written) and make the
+-- remaining defaults UTC too, so old and new rows compare consistently.
+--
+-- messages.create_date is left as it is: it is part of the primary key, which clients use in
+-- Message URLs (and message_revisions references), so converting it would break existing
+-- links. Messages created before this migration keep their create_date in the server's time zone.
+UPDATE users SET
+    last_access = last_access AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC',
+    create_date = create_date AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC';
+
+UPDATE messages SET
+    last_updated = last_updated AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC';
+
+-- Revisions are dated with the last_updated of the Message they were saved from.
+UPDATE message_revisions SET
+    revision_date = revision_date AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC';
+
+ALTER TABLE IF EXISTS users
+    ALTER COLUMN last_access SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+    ALTER COLUMN create_date SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');
+
+ALTER TABLE IF EXISTS messages
+    ALTER COLUMN create_date SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC'),
+    ALTER COLUMN last_updated SET DEFAULT (CURRENT_TIMESTAMP AT TIME ZONE 'UTC');
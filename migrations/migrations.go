@@ -0,0 +1,9 @@
+// Database schema migrations, embedded so the API binary doesn't need them on disk.
+// Postgres migrations are in the root of the package, and SQLite has its own set in
+// sqlite/ since its SQL dialect differs.
+package migrations
+
+import "embed"
+
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
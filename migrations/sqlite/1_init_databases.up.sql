@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS users
+(
+    uuid text NOT NULL,
+    email varchar(350),
+    api_key text,
+    last_access timestamp DEFAULT CURRENT_TIMESTAMP,
+    create_date timestamp DEFAULT CURRENT_TIMESTAMP,
+    full_name varchar(150),
+    CONSTRAINT users_pkey PRIMARY KEY (uuid),
+    CONSTRAINT unique_api_key UNIQUE (api_key),
+    CONSTRAINT unique_email UNIQUE (email)
+);
+
+CREATE TABLE IF NOT EXISTS messages
+(
+    uuid text NOT NULL,
+    create_date timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    message text,
+    is_palindrome boolean,
+    last_updated timestamp DEFAULT CURRENT_TIMESTAMP,
+    last_updated_by text,
+    logical_delete boolean DEFAULT false,
+    CONSTRAINT messages_pkey PRIMARY KEY (uuid, create_date),
+    CONSTRAINT last_updated_by FOREIGN KEY (last_updated_by)
+        REFERENCES users (uuid)
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION,
+    CONSTRAINT uuid FOREIGN KEY (uuid)
+        REFERENCES users (uuid)
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION
+);
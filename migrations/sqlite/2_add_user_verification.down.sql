@@ -0,0 +1,2 @@
+ALTER TABLE users DROP COLUMN verification_token;
+ALTER TABLE users DROP COLUMN verified;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN verified boolean NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN verification_token text NOT NULL DEFAULT '';
+
+-- Users created before verification existed are treated as verified so they can keep posting.
+UPDATE users SET verified = true;
@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS message_revisions
+(
+    uuid text NOT NULL,
+    create_date timestamp NOT NULL,
+    revision_date timestamp NOT NULL,
+    message text,
+    is_palindrome boolean,
+    last_updated_by text,
+    CONSTRAINT message_revisions_pkey PRIMARY KEY (uuid, create_date, revision_date),
+    CONSTRAINT message FOREIGN KEY (uuid, create_date)
+        REFERENCES messages (uuid, create_date)
+        ON UPDATE NO ACTION
+        ON DELETE CASCADE,
+    CONSTRAINT last_updated_by FOREIGN KEY (last_updated_by)
+        REFERENCES users (uuid)
+        ON UPDATE NO ACTION
+        ON DELETE NO ACTION
+);
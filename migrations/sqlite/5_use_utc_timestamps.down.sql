@@ -0,0 +1 @@
+-- Nothing to undo, see 5_use_utc_timestamps.up.sql.
@@ -0,0 +1 @@
+-- SQLite's CURRENT_TIMESTAMP is already UTC, so there is nothing to convert (see the Postgres migration).